@@ -0,0 +1,62 @@
+package vultr
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Address modes select the address used to reach the VPS
+const (
+	addressPublicV4 = "public-v4"
+	addressPublicV6 = "public-v6"
+	addressPrivate  = "private"
+)
+
+// validateAddressMode checks that the networking features required by the
+// address mode are enabled.
+func (d *Driver) validateAddressMode() error {
+	switch d.AddressMode {
+	case "", addressPublicV4:
+	case addressPublicV6:
+		if !d.IPv6 {
+			return fmt.Errorf("--vultr-address-mode=%s requires --vultr-ipv6", addressPublicV6)
+		}
+	case addressPrivate:
+		if !d.PrivateNetworking {
+			return fmt.Errorf("--vultr-address-mode=%s requires --vultr-private-networking", addressPrivate)
+		}
+	default:
+		return fmt.Errorf("Invalid address mode '%s'. Must be one of: %s, %s, %s",
+			d.AddressMode, addressPublicV4, addressPublicV6, addressPrivate)
+	}
+
+	return nil
+}
+
+// address returns the IP address of the VPS selected by the address mode.
+func (d *Driver) address() (string, error) {
+	switch d.AddressMode {
+	case addressPublicV6:
+		if d.IPv6Address == "" {
+			return "", fmt.Errorf("IPv6 address is not set")
+		}
+		return d.IPv6Address, nil
+	case addressPrivate:
+		if d.PrivateIP == "" {
+			return "", fmt.Errorf("Private IP address is not set")
+		}
+		return d.PrivateIP, nil
+	}
+
+	if !isValidIP(d.IPAddress) {
+		return "", fmt.Errorf("IP address is not set")
+	}
+	return d.IPAddress, nil
+}
+
+// dockerURL returns the URL of the Docker daemon listening on host. IPv6
+// addresses are enclosed in square brackets.
+func dockerURL(host string) string {
+	return "tcp://" + net.JoinHostPort(host, strconv.Itoa(dockerPort))
+}
@@ -0,0 +1,75 @@
+package vultr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressModes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		fqdn     bool
+		ip       string
+		hostname string
+	}{
+		{mode: addressPublicV4, ip: "1.2.3.4", hostname: "1.2.3.4"},
+		{mode: "", ip: "1.2.3.4", hostname: "1.2.3.4"},
+		{mode: addressPublicV6, ip: "2001:db8::1", hostname: "2001:db8::1"},
+		{mode: addressPrivate, ip: "10.99.0.10", hostname: "10.99.0.10"},
+		{mode: addressPublicV4, fqdn: true, ip: "default.example.com", hostname: "1.2.3.4"},
+		{mode: addressPublicV6, fqdn: true, ip: "default.example.com", hostname: "2001:db8::1"},
+		{mode: addressPrivate, fqdn: true, ip: "10.99.0.10", hostname: "10.99.0.10"},
+	}
+
+	for _, tt := range tests {
+		driver := NewDriver("default", "path")
+		driver.IPAddress = "1.2.3.4"
+		driver.IPv6Address = "2001:db8::1"
+		driver.PrivateIP = "10.99.0.10"
+		driver.DNSDomain = "example.com"
+		driver.DNSUseFQDN = tt.fqdn
+		driver.AddressMode = tt.mode
+
+		ip, err := driver.GetIP()
+		assert.NoError(t, err, tt.mode)
+		assert.Equal(t, tt.ip, ip, tt.mode)
+
+		hostname, err := driver.GetSSHHostname()
+		assert.NoError(t, err, tt.mode)
+		assert.Equal(t, tt.hostname, hostname, tt.mode)
+	}
+}
+
+func TestAddressNotSet(t *testing.T) {
+	driver := NewDriver("default", "path")
+	for _, mode := range []string{addressPublicV4, addressPublicV6, addressPrivate} {
+		driver.AddressMode = mode
+		_, err := driver.GetIP()
+		assert.Error(t, err, mode)
+	}
+}
+
+func TestValidateAddressMode(t *testing.T) {
+	driver := NewDriver("default", "path")
+	assert.NoError(t, driver.validateAddressMode())
+
+	driver.AddressMode = addressPublicV6
+	assert.Error(t, driver.validateAddressMode())
+	driver.IPv6 = true
+	assert.NoError(t, driver.validateAddressMode())
+
+	driver.AddressMode = addressPrivate
+	assert.Error(t, driver.validateAddressMode())
+	driver.PrivateNetworking = true
+	assert.NoError(t, driver.validateAddressMode())
+
+	driver.AddressMode = "public"
+	assert.Error(t, driver.validateAddressMode())
+}
+
+func TestDockerURL(t *testing.T) {
+	assert.Equal(t, "tcp://1.2.3.4:2376", dockerURL("1.2.3.4"))
+	assert.Equal(t, "tcp://[2001:db8::1]:2376", dockerURL("2001:db8::1"))
+	assert.Equal(t, "tcp://default.example.com:2376", dockerURL("default.example.com"))
+}
@@ -0,0 +1,193 @@
+package vultr
+
+import (
+	"fmt"
+	"strings"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	volumeKeep   = "keep"
+	volumeDelete = "delete"
+)
+
+// BlockStorageVolume is a Vultr block storage volume attached to the VPS
+type BlockStorageVolume struct {
+	ID string
+	// Created is true if the volume was created by the driver
+	Created bool
+	// Keep decides whether Remove detaches the volume and keeps it (true)
+	// or deletes it (false)
+	Keep bool
+}
+
+// parseBlockStorageIDs parses the values of --vultr-block-storage-id. Each
+// value is a volume ID optionally followed by ':keep' (the default) or
+// ':delete' to set what happens to the volume when the machine is removed.
+func parseBlockStorageIDs(values []string) ([]BlockStorageVolume, error) {
+	var volumes []BlockStorageVolume
+	for _, value := range values {
+		id, policy := value, volumeKeep
+		if i := strings.LastIndex(value, ":"); i != -1 {
+			id, policy = value[:i], value[i+1:]
+		}
+
+		if id == "" {
+			return nil, fmt.Errorf("Invalid block storage volume '%s': missing ID", value)
+		}
+		if policy != volumeKeep && policy != volumeDelete {
+			return nil, fmt.Errorf("Invalid block storage volume '%s': policy must be '%s' or '%s'", value, volumeKeep, volumeDelete)
+		}
+
+		volumes = append(volumes, BlockStorageVolume{ID: id, Keep: policy == volumeKeep})
+	}
+
+	return volumes, nil
+}
+
+// validateBlockStorage checks that the chosen region supports block storage
+// and that the volumes to attach exist in that region and are not in use.
+func (d *Driver) validateBlockStorage() error {
+	if d.BlockStorageSize == 0 && len(d.BlockStorage) == 0 {
+		return nil
+	}
+
+	if d.BlockStorageSize < 0 {
+		return fmt.Errorf("--vultr-block-storage-size must be a positive number of GB")
+	}
+
+	region, err := d.getRegion()
+	if err != nil {
+		return err
+	}
+
+	if !region.BlockStorage {
+		return fmt.Errorf("Block storage is not available in region %s (ID %d)", region.Name, region.ID)
+	}
+
+	for _, volume := range d.BlockStorage {
+		storage, err := d.getClient().GetBlockStorage(volume.ID)
+		if err != nil {
+			return err
+		}
+		if storage.RegionID != d.RegionID {
+			return fmt.Errorf("Block storage volume %s is located in region ID %d, not in region ID %d", volume.ID, storage.RegionID, d.RegionID)
+		}
+		if storage.AttachedTo != "" {
+			return fmt.Errorf("Block storage volume %s is already attached to VPS %s", volume.ID, storage.AttachedTo)
+		}
+	}
+
+	return nil
+}
+
+// createBlockStorage creates a new volume of BlockStorageSize GB in the
+// region of the VPS and adds it to the volumes to attach.
+func (d *Driver) createBlockStorage(tx *transaction) error {
+	if d.BlockStorageSize == 0 {
+		return nil
+	}
+
+	storage, err := d.getClient().CreateBlockStorage(d.MachineName, d.RegionID, d.BlockStorageSize)
+	if err != nil {
+		return err
+	}
+	log.Infof("Created block storage volume %s (%d GB)", storage.ID, d.BlockStorageSize)
+
+	volume := BlockStorageVolume{ID: storage.ID, Created: true, Keep: d.BlockStorageKeep}
+	d.BlockStorage = append(d.BlockStorage, volume)
+	tx.record("block storage volume "+storage.ID, func() error {
+		if err := d.getClient().DeleteBlockStorage(storage.ID); err != nil {
+			return err
+		}
+		d.BlockStorage = removeVolume(d.BlockStorage, storage.ID)
+		return nil
+	})
+
+	return nil
+}
+
+// attachBlockStorage attaches all volumes to the VPS.
+func (d *Driver) attachBlockStorage(tx *transaction) error {
+	for _, volume := range d.BlockStorage {
+		id := volume.ID
+		log.Infof("Attaching block storage volume %s", id)
+		if err := d.getClient().AttachBlockStorage(id, d.MachineID); err != nil {
+			return err
+		}
+		tx.record("attachment of block storage volume "+id, func() error {
+			return d.getClient().DetachBlockStorage(id)
+		})
+	}
+
+	return nil
+}
+
+// findBlockStorage returns the volume with the given ID, or nil if it is
+// not in storages.
+func findBlockStorage(storages []vultr.BlockStorage, id string) *vultr.BlockStorage {
+	for i := range storages {
+		if storages[i].ID == id {
+			return &storages[i]
+		}
+	}
+	return nil
+}
+
+// removeBlockStorage detaches the volumes from the VPS and deletes those
+// that are not to be kept. Volumes are only considered deleted if they are
+// missing from the volume list, any error listing them is returned.
+func (d *Driver) removeBlockStorage() error {
+	if len(d.BlockStorage) == 0 {
+		return nil
+	}
+
+	client := d.getClient()
+	storages, err := client.GetBlockStorages()
+	if err != nil {
+		return err
+	}
+
+	for _, volume := range d.BlockStorage {
+		storage := findBlockStorage(storages, volume.ID)
+		if storage == nil {
+			log.Infof("Block storage volume %s doesn't exist, assuming it is already deleted", volume.ID)
+			continue
+		}
+
+		if d.MachineID != "" && storage.AttachedTo == d.MachineID {
+			log.Infof("Detaching block storage volume %s", volume.ID)
+			if err := client.DetachBlockStorage(volume.ID); err != nil && !vultr.IsNotFound(err) {
+				return err
+			}
+		}
+
+		if volume.Keep {
+			log.Infof("Keeping block storage volume %s", volume.ID)
+			continue
+		}
+
+		log.Infof("Deleting block storage volume %s", volume.ID)
+		if err := client.DeleteBlockStorage(volume.ID); err != nil {
+			if !vultr.IsNotFound(err) {
+				return err
+			}
+			log.Infof("Block storage volume %s doesn't exist, assuming it is already deleted", volume.ID)
+		}
+	}
+
+	d.BlockStorage = nil
+	return nil
+}
+
+func removeVolume(volumes []BlockStorageVolume, id string) []BlockStorageVolume {
+	var result []BlockStorageVolume
+	for _, v := range volumes {
+		if v.ID != id {
+			result = append(result, v)
+		}
+	}
+	return result
+}
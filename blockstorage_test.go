@@ -0,0 +1,113 @@
+package vultr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBlockStorageIDs(t *testing.T) {
+	volumes, err := parseBlockStorageIDs([]string{"1313216", "1313217:keep", "1313218:delete"})
+	assert.NoError(t, err)
+	assert.Equal(t, []BlockStorageVolume{
+		{ID: "1313216", Keep: true},
+		{ID: "1313217", Keep: true},
+		{ID: "1313218", Keep: false},
+	}, volumes)
+
+	_, err = parseBlockStorageIDs([]string{"1313216:destroy"})
+	assert.Error(t, err)
+
+	_, err = parseBlockStorageIDs([]string{":delete"})
+	assert.Error(t, err)
+}
+
+const testRegionList = `{"1":{"DCID":"1","name":"New Jersey","regioncode":"EWR","block_storage":true},` +
+	`"7":{"DCID":"7","name":"Amsterdam","regioncode":"AMS","block_storage":false}}`
+
+func TestValidateBlockStorage(t *testing.T) {
+	driver, server := newTestDriver(map[string]string{
+		"/v1/regions/list": testRegionList,
+		"/v1/block/list": `[{"SUBID":1313216,"DCID":1,"size_gb":10,"attached_to_SUBID":null},` +
+			`{"SUBID":1313217,"DCID":7,"size_gb":10,"attached_to_SUBID":null},` +
+			`{"SUBID":1313218,"DCID":1,"size_gb":10,"attached_to_SUBID":576965}]`,
+	}, nil)
+	defer server.Close()
+
+	driver.RegionID = 1
+	driver.BlockStorage = []BlockStorageVolume{{ID: "1313216", Keep: true}}
+	assert.NoError(t, driver.validateBlockStorage())
+
+	driver.BlockStorage = []BlockStorageVolume{{ID: "1313217", Keep: true}}
+	assert.EqualError(t, driver.validateBlockStorage(), "Block storage volume 1313217 is located in region ID 7, not in region ID 1")
+
+	driver.BlockStorage = []BlockStorageVolume{{ID: "1313218", Keep: true}}
+	assert.EqualError(t, driver.validateBlockStorage(), "Block storage volume 1313218 is already attached to VPS 576965")
+
+	driver.BlockStorage = []BlockStorageVolume{{ID: "1313219", Keep: true}}
+	assert.Error(t, driver.validateBlockStorage())
+
+	driver.RegionID = 7
+	driver.BlockStorage = nil
+	driver.BlockStorageSize = 10
+	assert.EqualError(t, driver.validateBlockStorage(), "Block storage is not available in region Amsterdam (ID 7)")
+}
+
+func TestCreateAndAttachBlockStorage(t *testing.T) {
+	var calls []string
+	driver, server := newTestDriver(map[string]string{
+		"/v1/block/create": `{"SUBID":"1313220"}`,
+		"/v1/block/attach": "",
+		"/v1/block/detach": "",
+		"/v1/block/delete": "",
+	}, &calls)
+	defer server.Close()
+
+	driver.RegionID = 1
+	driver.MachineID = "576965"
+	driver.BlockStorage = []BlockStorageVolume{{ID: "1313216", Keep: true}}
+	driver.BlockStorageSize = 10
+
+	tx := &transaction{}
+	assert.NoError(t, driver.createBlockStorage(tx))
+	assert.Equal(t, []BlockStorageVolume{
+		{ID: "1313216", Keep: true},
+		{ID: "1313220", Created: true, Keep: false},
+	}, driver.BlockStorage)
+
+	assert.NoError(t, driver.attachBlockStorage(tx))
+	assert.Equal(t, []string{"/v1/block/create", "/v1/block/attach", "/v1/block/attach"}, calls)
+
+	// rolling back detaches both volumes and deletes the created one
+	calls = nil
+	assert.Empty(t, tx.rollback())
+	assert.Equal(t, []string{"/v1/block/detach", "/v1/block/detach", "/v1/block/delete"}, calls)
+	assert.Equal(t, []BlockStorageVolume{{ID: "1313216", Keep: true}}, driver.BlockStorage)
+}
+
+func TestRemoveBlockStorage(t *testing.T) {
+	var calls []string
+	driver, server := newTestDriver(map[string]string{
+		"/v1/block/list": `[{"SUBID":1313216,"DCID":1,"attached_to_SUBID":576965},` +
+			`{"SUBID":1313218,"DCID":1,"attached_to_SUBID":576965}]`,
+		"/v1/block/detach": "",
+		"/v1/block/delete": "",
+	}, &calls)
+
+	driver.MachineID = "576965"
+	driver.BlockStorage = []BlockStorageVolume{
+		{ID: "1313216", Keep: true},
+		{ID: "1313217", Keep: false},
+		{ID: "1313218", Keep: false},
+	}
+	assert.NoError(t, driver.removeBlockStorage())
+	assert.Equal(t, []string{"/v1/block/list", "/v1/block/detach", "/v1/block/detach", "/v1/block/delete"}, calls)
+	assert.Nil(t, driver.BlockStorage)
+
+	// volumes are not forgotten if they can't be listed
+	server.Close()
+	volumes := []BlockStorageVolume{{ID: "1313218", Keep: false}}
+	driver.BlockStorage = volumes
+	assert.Error(t, driver.removeBlockStorage())
+	assert.Equal(t, volumes, driver.BlockStorage)
+}
@@ -0,0 +1,119 @@
+package vultr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const cloudConfigHeader = "#cloud-config"
+
+// isCloudConfig reports whether the user data is a cloud-config document
+func isCloudConfig(userdata string) bool {
+	return strings.HasPrefix(strings.TrimSpace(userdata), cloudConfigHeader)
+}
+
+// mergeCloudConfig deep-merges the user supplied cloud-config into the
+// cloud-config generated by the driver. Conflicts are resolved as follows:
+//
+//   - maps (e.g. rancher.network.interfaces) are merged recursively
+//   - lists (e.g. ssh_authorized_keys, runcmd) are concatenated, generated
+//     entries first, and duplicates are dropped
+//   - write_files entries with the same path are replaced by the user's
+//   - for any other value the user's wins, except for the hostname, which
+//     always is the machine name
+func mergeCloudConfig(generated, user string) (string, error) {
+	var base, overlay map[interface{}]interface{}
+	if err := yaml.Unmarshal([]byte(generated), &base); err != nil {
+		return "", fmt.Errorf("Error parsing generated cloud-config: %v", err)
+	}
+	if err := yaml.Unmarshal([]byte(user), &overlay); err != nil {
+		return "", fmt.Errorf("Error parsing user data: %v", err)
+	}
+
+	if hostname, ok := overlay["hostname"]; ok && base["hostname"] != nil && hostname != base["hostname"] {
+		log.Warnf("Ignoring hostname '%v' in user data, the hostname is set to the machine name", hostname)
+		delete(overlay, "hostname")
+	}
+
+	merged := mergeValues(base, overlay, "")
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+
+	return cloudConfigHeader + "\n" + string(out), nil
+}
+
+func mergeValues(base, overlay interface{}, key string) interface{} {
+	switch b := base.(type) {
+	case map[interface{}]interface{}:
+		o, ok := overlay.(map[interface{}]interface{})
+		if !ok {
+			return overlay
+		}
+		result := make(map[interface{}]interface{}, len(b)+len(o))
+		for k, v := range b {
+			result[k] = v
+		}
+		for k, v := range o {
+			if existing, ok := result[k]; ok {
+				result[k] = mergeValues(existing, v, fmt.Sprintf("%v", k))
+			} else {
+				result[k] = v
+			}
+		}
+		return result
+	case []interface{}:
+		o, ok := overlay.([]interface{})
+		if !ok {
+			return overlay
+		}
+		if key == "write_files" {
+			return mergeWriteFiles(b, o)
+		}
+		result := append([]interface{}{}, b...)
+		for _, v := range o {
+			if !containsValue(result, v) {
+				result = append(result, v)
+			}
+		}
+		return result
+	}
+
+	return overlay
+}
+
+// mergeWriteFiles appends the user's write_files entries, replacing the
+// generated entries with the same path.
+func mergeWriteFiles(base, overlay []interface{}) []interface{} {
+	paths := make(map[interface{}]bool)
+	for _, f := range overlay {
+		if m, ok := f.(map[interface{}]interface{}); ok && m["path"] != nil {
+			paths[m["path"]] = true
+		}
+	}
+
+	var result []interface{}
+	for _, f := range base {
+		if m, ok := f.(map[interface{}]interface{}); ok && paths[m["path"]] {
+			log.Infof("User data replaces generated file %v", m["path"])
+			continue
+		}
+		result = append(result, f)
+	}
+
+	return append(result, overlay...)
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, v := range list {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package vultr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const testUserCloudConfig = `#cloud-config
+hostname: other
+ssh_authorized_keys:
+  - ssh-rsa BBBB oncall@example.com
+  - ssh-rsa AAAA default
+write_files:
+  - path: /etc/motd
+    content: hello
+rancher:
+  network:
+    dns:
+      nameservers: [1.1.1.1]
+    interfaces:
+      eth0:
+        mtu: 1400
+  services:
+    nginx:
+      image: nginx
+`
+
+func TestMergeCloudConfig(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.VultrPublicKey = "ssh-rsa AAAA default"
+	driver.PrivateNetworking = true
+
+	generated, err := driver.getCloudConfig()
+	assert.NoError(t, err)
+
+	merged, err := mergeCloudConfig(generated, testUserCloudConfig)
+	assert.NoError(t, err)
+	assert.True(t, isCloudConfig(merged))
+
+	var config struct {
+		Hostname          string   `yaml:"hostname"`
+		SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys"`
+		WriteFiles        []struct {
+			Path string `yaml:"path"`
+		} `yaml:"write_files"`
+		Rancher struct {
+			Network struct {
+				DNS struct {
+					Nameservers []string `yaml:"nameservers"`
+				} `yaml:"dns"`
+				Interfaces map[string]map[string]interface{} `yaml:"interfaces"`
+			} `yaml:"network"`
+			Services map[string]interface{} `yaml:"services"`
+		} `yaml:"rancher"`
+	}
+	assert.NoError(t, yaml.Unmarshal([]byte(merged), &config))
+
+	assert.Equal(t, "default", config.Hostname)
+	assert.Equal(t, []string{"ssh-rsa AAAA default", "ssh-rsa BBBB oncall@example.com"}, config.SSHAuthorizedKeys)
+	if assert.Len(t, config.WriteFiles, 2) {
+		assert.Equal(t, "/opt/rancher/bin/start.sh", config.WriteFiles[0].Path)
+		assert.Equal(t, "/etc/motd", config.WriteFiles[1].Path)
+	}
+	assert.Equal(t, []string{"1.1.1.1"}, config.Rancher.Network.DNS.Nameservers)
+	assert.Equal(t, true, config.Rancher.Network.Interfaces["eth0"]["dhcp"])
+	assert.Equal(t, 1400, config.Rancher.Network.Interfaces["eth0"]["mtu"])
+	assert.Equal(t, "$private_ipv4/16", config.Rancher.Network.Interfaces["eth1"]["address"])
+	assert.Contains(t, config.Rancher.Services, "nginx")
+}
+
+func TestMergeCloudConfigReplacesWriteFiles(t *testing.T) {
+	generated := "#cloud-config\nwrite_files:\n  - path: /a\n    content: generated\n  - path: /b\n"
+	user := "#cloud-config\nwrite_files:\n  - path: /a\n    content: user\n"
+
+	merged, err := mergeCloudConfig(generated, user)
+	assert.NoError(t, err)
+	assert.Equal(t, "#cloud-config\nwrite_files:\n- path: /b\n- content: user\n  path: /a\n", merged)
+}
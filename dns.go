@@ -0,0 +1,128 @@
+package vultr
+
+import (
+	"fmt"
+	"strings"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// fqdn returns the fully qualified domain name published for the machine
+func (d *Driver) fqdn() string {
+	return d.MachineName + "." + strings.TrimSuffix(d.DNSDomain, ".")
+}
+
+// validateDNSDomain checks that the domain is managed by Vultr DNS and that
+// it doesn't already contain address records for the machine name.
+func (d *Driver) validateDNSDomain() error {
+	if d.DNSDomain == "" {
+		return nil
+	}
+
+	client := d.getClient()
+	domains, err := client.GetDNSDomains()
+	if err != nil {
+		return err
+	}
+
+	domain := strings.TrimSuffix(d.DNSDomain, ".")
+	found := false
+	for _, dom := range domains {
+		if strings.EqualFold(dom.Domain, domain) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("Domain %s is not managed by Vultr DNS in your account", domain)
+	}
+
+	records, err := client.GetDNSRecords(domain)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if strings.EqualFold(record.Name, d.MachineName) && (record.Type == "A" || record.Type == "AAAA") {
+			return fmt.Errorf("DNS record %s (%s) already exists", d.fqdn(), record.Type)
+		}
+	}
+
+	return nil
+}
+
+// createDNSRecords publishes A (and AAAA) records for the machine.
+func (d *Driver) createDNSRecords(tx *transaction) error {
+	if d.DNSDomain == "" {
+		return nil
+	}
+
+	addresses := map[string]string{"A": d.IPAddress}
+	if d.IPv6 && d.IPv6Address != "" {
+		addresses["AAAA"] = d.IPv6Address
+	}
+
+	client := d.getClient()
+	domain := strings.TrimSuffix(d.DNSDomain, ".")
+	for _, rtype := range []string{"A", "AAAA"} {
+		data, ok := addresses[rtype]
+		if !ok {
+			continue
+		}
+
+		log.Infof("Creating DNS record %s %s %s", d.fqdn(), rtype, data)
+		if err := client.CreateDNSRecord(domain, d.MachineName, rtype, data, 0, d.DNSTTL); err != nil {
+			return err
+		}
+
+		// the API doesn't return the ID of a new record, so look it up
+		records, err := client.GetDNSRecords(domain)
+		if err != nil {
+			return err
+		}
+		record, err := findDNSRecord(records, d.MachineName, rtype, data)
+		if err != nil {
+			return err
+		}
+
+		d.DNSRecordIDs = append(d.DNSRecordIDs, record.RecordID)
+		id := record.RecordID
+		tx.record(fmt.Sprintf("DNS record %d", id), func() error {
+			return client.DeleteDNSRecord(domain, id)
+		})
+	}
+
+	return nil
+}
+
+// removeDNSRecords deletes the records created by createDNSRecords.
+func (d *Driver) removeDNSRecords() error {
+	if d.DNSDomain == "" {
+		return nil
+	}
+
+	client := d.getClient()
+	domain := strings.TrimSuffix(d.DNSDomain, ".")
+	for len(d.DNSRecordIDs) > 0 {
+		id := d.DNSRecordIDs[0]
+		log.Infof("Deleting DNS record %d", id)
+		if err := client.DeleteDNSRecord(domain, id); err != nil {
+			if !vultr.IsNotFound(err) {
+				return err
+			}
+			log.Infof("DNS record doesn't exist, assuming it is already deleted")
+		}
+		d.DNSRecordIDs = d.DNSRecordIDs[1:]
+	}
+
+	return nil
+}
+
+func findDNSRecord(records []vultr.DNSRecord, name, rtype, data string) (*vultr.DNSRecord, error) {
+	for _, record := range records {
+		if strings.EqualFold(record.Name, name) && record.Type == rtype && record.Data == data {
+			return &record, nil
+		}
+	}
+	return nil, fmt.Errorf("Unable to find the %s record created for %s", rtype, name)
+}
@@ -0,0 +1,99 @@
+package vultr
+
+import (
+	"testing"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDNSRecord(t *testing.T) {
+	records := []vultr.DNSRecord{
+		{RecordID: 1, Type: "A", Name: "web", Data: "1.2.3.4"},
+		{RecordID: 2, Type: "A", Name: "default", Data: "1.2.3.4"},
+		{RecordID: 3, Type: "AAAA", Name: "default", Data: "2001:db8::1"},
+	}
+
+	record, err := findDNSRecord(records, "default", "A", "1.2.3.4")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, record.RecordID)
+	}
+
+	record, err = findDNSRecord(records, "default", "AAAA", "2001:db8::1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, record.RecordID)
+	}
+
+	_, err = findDNSRecord(records, "default", "A", "5.6.7.8")
+	assert.Error(t, err)
+}
+
+func TestGetIPWithFQDN(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.IPAddress = "1.2.3.4"
+	driver.DNSDomain = "example.com."
+
+	ip, err := driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", ip)
+
+	driver.DNSUseFQDN = true
+	ip, err = driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "default.example.com", ip)
+
+	hostname, err := driver.GetSSHHostname()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", hostname)
+}
+
+func TestCreateDNSRecords(t *testing.T) {
+	var calls []string
+	driver, server := newTestDriver(map[string]string{
+		"/v1/dns/create_record": "",
+		"/v1/dns/delete_record": "",
+		"/v1/dns/records": `[{"RECORDID":1,"type":"A","name":"web","data":"1.2.3.4"},` +
+			`{"RECORDID":2,"type":"A","name":"default","data":"5.6.7.8"},` +
+			`{"RECORDID":3,"type":"AAAA","name":"default","data":"2001:db8::1"}]`,
+	}, &calls)
+	defer server.Close()
+
+	driver.DNSDomain = "example.com."
+	driver.IPAddress = "5.6.7.8"
+	driver.IPv6 = true
+	driver.IPv6Address = "2001:db8::1"
+
+	tx := &transaction{}
+	assert.NoError(t, driver.createDNSRecords(tx))
+	assert.Equal(t, []int{2, 3}, driver.DNSRecordIDs)
+	assert.Equal(t, []string{"/v1/dns/create_record", "/v1/dns/records", "/v1/dns/create_record", "/v1/dns/records"}, calls)
+
+	calls = nil
+	assert.Empty(t, tx.rollback())
+	assert.Equal(t, []string{"/v1/dns/delete_record", "/v1/dns/delete_record"}, calls)
+
+	// the created record can't be found
+	driver.DNSRecordIDs = nil
+	driver.IPv6 = false
+	driver.IPAddress = "9.9.9.9"
+	assert.Error(t, driver.createDNSRecords(&transaction{}))
+	assert.Empty(t, driver.DNSRecordIDs)
+}
+
+func TestRemoveDNSRecords(t *testing.T) {
+	var calls []string
+	driver, server := newTestDriver(map[string]string{}, &calls)
+
+	// records that are already gone (404) are skipped
+	driver.DNSDomain = "example.com"
+	driver.DNSRecordIDs = []int{2, 3}
+	assert.NoError(t, driver.removeDNSRecords())
+	assert.Equal(t, []string{"/v1/dns/delete_record", "/v1/dns/delete_record"}, calls)
+	assert.Empty(t, driver.DNSRecordIDs)
+
+	// other errors keep the remaining records
+	server.Close()
+	driver.DNSRecordIDs = []int{2, 3}
+	assert.Error(t, driver.removeDNSRecords())
+	assert.Equal(t, []int{2, 3}, driver.DNSRecordIDs)
+}
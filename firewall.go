@@ -0,0 +1,129 @@
+package vultr
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	dockerPort      = 2376
+	swarmMasterPort = 3376
+)
+
+// parseFirewallSources parses the source networks allowed by the managed
+// firewall group. Without sources, SSH and Docker are reachable from
+// anywhere over IPv4, and over IPv6 if it is enabled.
+func parseFirewallSources(sources []string, ipv6 bool) ([]*net.IPNet, error) {
+	if len(sources) == 0 {
+		sources = []string{"0.0.0.0/0"}
+		if ipv6 {
+			sources = append(sources, "::/0")
+		}
+	}
+
+	var networks []*net.IPNet
+	for _, source := range sources {
+		_, network, err := net.ParseCIDR(source)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid firewall source '%s': %v", source, err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// firewallPorts returns the TCP ports opened by the managed firewall group.
+func (d *Driver) firewallPorts() []int {
+	ports := []int{d.SSHPort, dockerPort}
+	if d.SwarmMaster {
+		ports = append(ports, swarmMasterPort)
+	}
+	return ports
+}
+
+// createFirewallGroup creates a firewall group for the machine that only
+// allows inbound SSH and Docker traffic from the configured sources.
+func (d *Driver) createFirewallGroup(tx *transaction) error {
+	networks, err := parseFirewallSources(d.FirewallSources, d.IPv6)
+	if err != nil {
+		return err
+	}
+
+	client := d.getClient()
+	groupID, err := client.CreateFirewallGroup("docker-machine " + d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.FirewallGroupID = groupID
+	tx.record("firewall group "+groupID, d.deleteFirewallGroup)
+	log.Infof("Created firewall group %s", groupID)
+
+	for _, port := range d.firewallPorts() {
+		for _, network := range networks {
+			log.Debugf("Allowing TCP port %d from %s", port, network)
+			if _, err := client.CreateFirewallRule(groupID, "tcp", strconv.Itoa(port), network); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteFirewallGroup deletes the managed firewall group.
+func (d *Driver) deleteFirewallGroup() error {
+	if d.FirewallGroupID == "" {
+		return nil
+	}
+	if err := d.getClient().DeleteFirewallGroup(d.FirewallGroupID); err != nil {
+		return err
+	}
+	d.FirewallGroupID = ""
+	return nil
+}
+
+// removeFirewallGroup deletes the managed firewall group unless servers
+// other than machineID have been assigned to it in the meantime.
+func (d *Driver) removeFirewallGroup(machineID string) error {
+	if !d.ManagedFirewall || d.FirewallGroupID == "" {
+		return nil
+	}
+
+	servers, err := d.getClient().GetServers()
+	if err != nil {
+		return err
+	}
+
+	if users := firewallGroupUsers(servers, d.FirewallGroupID, machineID); len(users) > 0 {
+		log.Infof("Firewall group %s is still used by VPS %v, not deleting it", d.FirewallGroupID, users)
+		return nil
+	}
+
+	log.Infof("Deleting firewall group %s", d.FirewallGroupID)
+	if err := d.deleteFirewallGroup(); err != nil {
+		if !vultr.IsNotFound(err) {
+			return err
+		}
+		log.Infof("Firewall group doesn't exist, assuming it is already deleted")
+		d.FirewallGroupID = ""
+	}
+
+	return nil
+}
+
+// firewallGroupUsers returns the IDs of the servers other than machineID
+// that are assigned to the firewall group.
+func firewallGroupUsers(servers []vultr.Server, groupID, machineID string) []string {
+	var users []string
+	for _, server := range servers {
+		if server.FirewallGroupID == groupID && server.ID != machineID {
+			users = append(users, server.ID)
+		}
+	}
+	return users
+}
@@ -0,0 +1,48 @@
+package vultr
+
+import (
+	"testing"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFirewallSources(t *testing.T) {
+	tests := []struct {
+		sources  []string
+		ipv6     bool
+		networks []string
+		err      bool
+	}{
+		{sources: nil, ipv6: false, networks: []string{"0.0.0.0/0"}},
+		{sources: nil, ipv6: true, networks: []string{"0.0.0.0/0", "::/0"}},
+		{sources: []string{"10.1.2.3/8", "2001:db8::/32"}, networks: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{sources: []string{"10.1.2.3"}, err: true},
+	}
+
+	for _, tt := range tests {
+		networks, err := parseFirewallSources(tt.sources, tt.ipv6)
+		if tt.err {
+			assert.Error(t, err, "%v", tt.sources)
+			continue
+		}
+		assert.NoError(t, err, "%v", tt.sources)
+
+		var result []string
+		for _, network := range networks {
+			result = append(result, network.String())
+		}
+		assert.Equal(t, tt.networks, result)
+	}
+}
+
+func TestFirewallGroupUsers(t *testing.T) {
+	servers := []vultr.Server{
+		{ID: "1", FirewallGroupID: "abc"},
+		{ID: "2", FirewallGroupID: "abc"},
+		{ID: "3", FirewallGroupID: "def"},
+	}
+
+	assert.Equal(t, []string{"2"}, firewallGroupUsers(servers, "abc", "1"))
+	assert.Empty(t, firewallGroupUsers(servers, "def", "3"))
+}
@@ -0,0 +1,268 @@
+package vultr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	registerProfile("flatcar", flatcarProfile{})
+}
+
+const (
+	defaultFlatcarChannel = "stable"
+	defaultFlatcarVersion = "current"
+	ignitionVersion       = "3.3.0"
+	// flatcarDataLabel is the label of the filesystem on the VPS disk
+	// that holds /var/lib/docker, as Flatcar itself runs from RAM.
+	flatcarDataLabel = "docker"
+)
+
+// flatcarChannels are the Flatcar release channels that provide PXE images
+var flatcarChannels = []string{"stable", "beta", "alpha", "lts"}
+
+// flatcarPrivateNetworkScript configures the private network interface with
+// the address Vultr exposes in the EC2 compatible metadata.
+const flatcarPrivateNetworkScript = `#!/bin/sh
+set -e
+ip=$(curl -sf --retry 10 http://169.254.169.254/latest/meta-data/local-ipv4)
+cat > /etc/systemd/network/20-eth1.network <<EOF
+[Match]
+Name=eth1
+
+[Network]
+Address=${ip}/16
+
+[Link]
+MTUBytes=1450
+EOF
+systemctl restart systemd-networkd
+`
+
+const flatcarPrivateNetworkUnit = `[Unit]
+Description=Configure the Vultr private network interface
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=true
+ExecStart=/opt/bin/vultr-private-network
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const flatcarDataMountUnit = `[Unit]
+Description=Mount the Docker data filesystem
+Before=docker.service
+
+[Mount]
+What=/dev/disk/by-label/` + flatcarDataLabel + `
+Where=/var/lib/docker
+Type=ext4
+
+[Install]
+WantedBy=local-fs.target
+`
+
+// ignitionConfig is the subset of the Ignition config specification
+// used to provision Flatcar.
+type ignitionConfig struct {
+	Ignition ignitionSection `json:"ignition"`
+	Passwd   ignitionPasswd  `json:"passwd"`
+	Storage  ignitionStorage `json:"storage"`
+	Systemd  ignitionSystemd `json:"systemd"`
+}
+
+type ignitionSection struct {
+	Version string                `json:"version"`
+	Config  *ignitionConfigMerges `json:"config,omitempty"`
+}
+
+type ignitionConfigMerges struct {
+	Merge []ignitionResource `json:"merge"`
+}
+
+type ignitionResource struct {
+	Source string `json:"source"`
+}
+
+type ignitionPasswd struct {
+	Users []ignitionUser `json:"users"`
+}
+
+type ignitionUser struct {
+	Name              string   `json:"name"`
+	SSHAuthorizedKeys []string `json:"sshAuthorizedKeys"`
+}
+
+type ignitionStorage struct {
+	Filesystems []ignitionFilesystem `json:"filesystems"`
+	Files       []ignitionFile       `json:"files"`
+}
+
+type ignitionFilesystem struct {
+	Device         string `json:"device"`
+	Format         string `json:"format"`
+	Label          string `json:"label"`
+	WipeFilesystem bool   `json:"wipeFilesystem"`
+}
+
+type ignitionFile struct {
+	Path      string           `json:"path"`
+	Mode      int              `json:"mode"`
+	Overwrite bool             `json:"overwrite"`
+	Contents  ignitionResource `json:"contents"`
+}
+
+type ignitionSystemd struct {
+	Units []ignitionUnit `json:"units"`
+}
+
+type ignitionUnit struct {
+	Name     string `json:"name"`
+	Enabled  bool   `json:"enabled"`
+	Contents string `json:"contents"`
+}
+
+// validateFlatcarChannel checks the value of --vultr-flatcar-channel
+func validateFlatcarChannel(channel string) error {
+	for _, c := range flatcarChannels {
+		if c == channel {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid Flatcar channel '%s'. Must be one of: %s", channel, strings.Join(flatcarChannels, ", "))
+}
+
+// isIgnitionConfig reports whether the user data is an Ignition config,
+// which unlike cloud-config has no header and is a JSON object.
+func isIgnitionConfig(userdata string) bool {
+	return strings.HasPrefix(strings.TrimSpace(userdata), "{")
+}
+
+// dataURL encodes content as a base64 RFC 2397 data URL, the inline
+// source of Ignition resources.
+func dataURL(content string) string {
+	return "data:;base64," + base64.StdEncoding.EncodeToString([]byte(content))
+}
+
+// flatcarPXEScript generates the iPXE script that boots the Flatcar PXE
+// image. Ignition fetches its config from the Vultr user data.
+func flatcarPXEScript(channel, version string) string {
+	content := `#!ipxe
+set base-url https://%s.release.flatcar-linux.net/amd64-usr/%s
+kernel ${base-url}/flatcar_production_pxe.vmlinuz initrd=flatcar_production_pxe_image.cpio.gz flatcar.first_boot=1 ignition.config.url=http://169.254.169.254/latest/user-data
+initrd ${base-url}/flatcar_production_pxe_image.cpio.gz
+boot`
+
+	return fmt.Sprintf(content, channel, version)
+}
+
+// flatcarIgnitionConfig generates the Ignition config that provisions the
+// SSH keys of the 'core' user, the hostname, the Docker data disk and the
+// private network interface. The Ignition configs given as user data are
+// merged into it by Ignition.
+func flatcarIgnitionConfig(hostname string, publicKeys []string, privateNetworking bool, userConfigs []string) (string, error) {
+	var keys []string
+	for _, key := range publicKeys {
+		keys = append(keys, strings.TrimSpace(key))
+	}
+
+	config := ignitionConfig{
+		Ignition: ignitionSection{Version: ignitionVersion},
+		Passwd: ignitionPasswd{
+			Users: []ignitionUser{{Name: "core", SSHAuthorizedKeys: keys}},
+		},
+		Storage: ignitionStorage{
+			Filesystems: []ignitionFilesystem{{Device: "/dev/vda", Format: "ext4", Label: flatcarDataLabel}},
+			Files: []ignitionFile{
+				{Path: "/etc/hostname", Mode: 0644, Overwrite: true, Contents: ignitionResource{Source: dataURL(hostname)}},
+			},
+		},
+		Systemd: ignitionSystemd{
+			Units: []ignitionUnit{{Name: "var-lib-docker.mount", Enabled: true, Contents: flatcarDataMountUnit}},
+		},
+	}
+
+	if privateNetworking {
+		config.Storage.Files = append(config.Storage.Files, ignitionFile{
+			Path:      "/opt/bin/vultr-private-network",
+			Mode:      0755,
+			Overwrite: true,
+			Contents:  ignitionResource{Source: dataURL(flatcarPrivateNetworkScript)},
+		})
+		config.Systemd.Units = append(config.Systemd.Units, ignitionUnit{
+			Name:     "vultr-private-network.service",
+			Enabled:  true,
+			Contents: flatcarPrivateNetworkUnit,
+		})
+	}
+
+	if len(userConfigs) > 0 {
+		config.Ignition.Config = &ignitionConfigMerges{}
+		for _, userConfig := range userConfigs {
+			if !json.Valid([]byte(userConfig)) {
+				return "", fmt.Errorf("User data is not a valid Ignition config")
+			}
+			config.Ignition.Config.Merge = append(config.Ignition.Config.Merge, ignitionResource{Source: dataURL(userConfig)})
+		}
+	}
+
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+// flatcarProfile provisions Flatcar Container Linux, configured by Ignition
+type flatcarProfile struct{}
+
+func (flatcarProfile) Description(d *Driver) string {
+	return fmt.Sprintf("Flatcar Container Linux (%s/%s)", d.FlatcarChannel, d.FlatcarVersion)
+}
+
+func (flatcarProfile) Validate(d *Driver) error {
+	if d.FlatcarVersion == "" {
+		return fmt.Errorf("--vultr-flatcar-version must not be empty")
+	}
+	return validateFlatcarChannel(d.FlatcarChannel)
+}
+
+func (flatcarProfile) SSHUser() string {
+	return "core"
+}
+
+func (flatcarProfile) PXEScript(d *Driver) string {
+	return flatcarPXEScript(d.FlatcarChannel, d.FlatcarVersion)
+}
+
+func (flatcarProfile) ValidateUserData(path, content string) error {
+	if !isIgnitionConfig(content) {
+		return fmt.Errorf("--vultr-userdata must be an Ignition config (a JSON object) when provisioning Flatcar Container Linux: %s", path)
+	}
+	return nil
+}
+
+// UserData merges the user's Ignition configs into the generated Ignition config
+func (flatcarProfile) UserData(d *Driver, parts []userDataPart) (string, error) {
+	publicKey, err := d.publicKey()
+	if err != nil {
+		return "", err
+	}
+
+	var userConfigs []string
+	for _, part := range parts {
+		userConfigs = append(userConfigs, part.Content)
+	}
+	publicKeys := append([]string{publicKey}, d.AuthorizedKeys...)
+	return flatcarIgnitionConfig(d.MachineName, publicKeys, d.PrivateNetworking, userConfigs)
+}
+
+func (flatcarProfile) PostCreate(d *Driver) error {
+	return checkOSRelease(d, "flatcar")
+}
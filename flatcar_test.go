@@ -0,0 +1,72 @@
+package vultr
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// assertGolden compares actual with the golden file testdata/name.
+// Run the tests with -update to rewrite the golden files.
+func assertGolden(t *testing.T, name, actual string) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(expected), actual, name)
+}
+
+func TestFlatcarIgnitionConfig(t *testing.T) {
+	tests := []struct {
+		golden            string
+		privateNetworking bool
+		userConfigs       []string
+	}{
+		{"flatcar-ignition.golden", false, nil},
+		{"flatcar-ignition-private.golden", true, nil},
+		{"flatcar-ignition-merge.golden", false, []string{`{"ignition":{"version":"3.3.0"},"systemd":{"units":[{"name":"app.service","enabled":true}]}}`}},
+	}
+
+	for _, tt := range tests {
+		config, err := flatcarIgnitionConfig("web-1", []string{"ssh-rsa AAAA default\n"}, tt.privateNetworking, tt.userConfigs)
+		if assert.NoError(t, err, tt.golden) {
+			assertGolden(t, tt.golden, config)
+		}
+	}
+
+	_, err := flatcarIgnitionConfig("web-1", []string{"ssh-rsa AAAA default"}, false, []string{"{invalid"})
+	assert.Error(t, err)
+}
+
+func TestFlatcarPXEScript(t *testing.T) {
+	assertGolden(t, "flatcar-pxe.golden", flatcarPXEScript("stable", "current"))
+}
+
+func TestValidateFlatcarChannel(t *testing.T) {
+	assert.NoError(t, validateFlatcarChannel("stable"))
+	assert.NoError(t, validateFlatcarChannel("lts"))
+	assert.Error(t, validateFlatcarChannel("edge"))
+}
+
+func TestBuildUserDataFlatcar(t *testing.T) {
+	driver := NewDriver("web-1", "")
+	driver.OSProfile = "flatcar"
+	driver.FlatcarChannel = "stable"
+	driver.VultrPublicKey = "ssh-rsa AAAA default"
+
+	userdata, err := driver.buildUserData()
+	assert.NoError(t, err)
+	assertGolden(t, "flatcar-ignition.golden", userdata)
+}
@@ -0,0 +1,136 @@
+package vultr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+)
+
+// hasPlanRequirements reports whether the plan is selected by the resource
+// requirements and price ceiling instead of --vultr-plan-id.
+func (d *Driver) hasPlanRequirements() bool {
+	return d.MinVCpus > 0 || d.MinRAMMB > 0 || d.MinDiskGB > 0 || d.MaxMonthlyPrice > 0 || d.PlanType != ""
+}
+
+// planRequirements describes the requirements for log and error messages
+func (d *Driver) planRequirements() string {
+	var req []string
+	if d.MinVCpus > 0 {
+		req = append(req, fmt.Sprintf("at least %d vCPUs", d.MinVCpus))
+	}
+	if d.MinRAMMB > 0 {
+		req = append(req, fmt.Sprintf("at least %d MB RAM", d.MinRAMMB))
+	}
+	if d.MinDiskGB > 0 {
+		req = append(req, fmt.Sprintf("at least %d GB disk", d.MinDiskGB))
+	}
+	if d.MaxMonthlyPrice > 0 {
+		req = append(req, fmt.Sprintf("at most $%.2f/month", d.MaxMonthlyPrice))
+	}
+	if d.PlanType != "" {
+		req = append(req, fmt.Sprintf("type %s", d.PlanType))
+	}
+	return strings.Join(req, ", ")
+}
+
+// planNumber parses the RAM, disk or price of a plan. Values the API
+// returns in other formats (e.g. the disks of dedicated plans) count as 0.
+func planNumber(value string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func planLabel(p vultr.Plan) string {
+	return fmt.Sprintf("%s (ID %d, %d vCPUs, %s MB RAM, %s GB disk, $%.2f/month)", planSlug(p), p.ID, p.VCpus, p.RAM, p.Disk, planNumber(p.Price))
+}
+
+// planMismatches returns the requirements the plan doesn't meet, or nil if
+// it can be used.
+func (d *Driver) planMismatches(p vultr.Plan, available map[int]bool) []string {
+	var mismatches []string
+	if !available[p.ID] {
+		mismatches = append(mismatches, "not available in the region")
+	}
+	if p.VCpus < d.MinVCpus {
+		mismatches = append(mismatches, fmt.Sprintf("%d vCPUs", p.VCpus))
+	}
+	if planNumber(p.RAM) < float64(d.MinRAMMB) {
+		mismatches = append(mismatches, fmt.Sprintf("%s MB RAM", p.RAM))
+	}
+	if planNumber(p.Disk) < float64(d.MinDiskGB) {
+		mismatches = append(mismatches, fmt.Sprintf("%s GB disk", p.Disk))
+	}
+	if d.MaxMonthlyPrice > 0 && planNumber(p.Price) > d.MaxMonthlyPrice {
+		mismatches = append(mismatches, fmt.Sprintf("$%.2f/month", planNumber(p.Price)))
+	}
+	if d.PlanType != "" && !strings.EqualFold(p.Type, d.PlanType) {
+		mismatches = append(mismatches, fmt.Sprintf("type %s", p.Type))
+	}
+	return mismatches
+}
+
+// selectPlan returns the cheapest of plans that meets the requirements and
+// is available in the region. If none does, the error lists the plans
+// missing the fewest requirements.
+func (d *Driver) selectPlan(plans []vultr.Plan, availablePlans []int) (*vultr.Plan, int, error) {
+	available := make(map[int]bool)
+	for _, id := range availablePlans {
+		available[id] = true
+	}
+
+	sorted := append([]vultr.Plan(nil), plans...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return planNumber(sorted[i].Price) < planNumber(sorted[j].Price)
+	})
+
+	var matches []vultr.Plan
+	for _, p := range sorted {
+		if len(d.planMismatches(p, available)) == 0 {
+			matches = append(matches, p)
+		}
+	}
+	if len(matches) > 0 {
+		return &matches[0], len(matches), nil
+	}
+
+	// the cheapest plans missing the fewest requirements come first
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(d.planMismatches(sorted[i], available)) < len(d.planMismatches(sorted[j], available))
+	})
+	if len(sorted) > maxSuggestions {
+		sorted = sorted[:maxSuggestions]
+	}
+	var closest []string
+	for _, p := range sorted {
+		closest = append(closest, fmt.Sprintf("%s: %s", planLabel(p), strings.Join(d.planMismatches(p, available), ", ")))
+	}
+	if len(closest) == 0 {
+		return nil, 0, fmt.Errorf("No plan with %s found in region ID %d", d.planRequirements(), d.RegionID)
+	}
+	return nil, 0, fmt.Errorf("No plan with %s found in region ID %d. Closest plans: %s", d.planRequirements(), d.RegionID, strings.Join(closest, "; "))
+}
+
+// planForRegion returns the plan to use in a region: the cheapest plan
+// meeting the requirements, or PlanID if there are none.
+func (d *Driver) planForRegion(plans []vultr.Plan, available []int) (*vultr.Plan, int, error) {
+	if d.hasPlanRequirements() {
+		return d.selectPlan(plans, available)
+	}
+
+	for _, id := range available {
+		if id == d.PlanID {
+			return nil, 0, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("PlanID %d not available in the chosen region. Available plans for RegionID %d: %v", d.PlanID, d.RegionID, available)
+}
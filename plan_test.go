@@ -0,0 +1,117 @@
+package vultr
+
+import (
+	"testing"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPricedPlans = []vultr.Plan{
+	{ID: 203, VCpus: 2, RAM: "4096", Disk: "80", Price: "20.00", Type: "SSD"},
+	{ID: 201, VCpus: 1, RAM: "1024", Disk: "25", Price: "5.00", Type: "SSD"},
+	{ID: 202, VCpus: 1, RAM: "2048", Disk: "55", Price: "10.00", Type: "SSD"},
+	{ID: 401, VCpus: 1, RAM: "2048", Disk: "64", Price: "12.00", Type: "HIGHFREQUENCY"},
+	{ID: 204, VCpus: 4, RAM: "8192", Disk: "160", Price: "40.00", Type: "SSD"},
+}
+
+func TestSelectPlan(t *testing.T) {
+	tests := []struct {
+		minVCpus, minRAM, minDisk int
+		maxPrice                  float64
+		planType                  string
+		id                        int
+		err                       string
+	}{
+		{minRAM: 2048, id: 202},
+		{minRAM: 2048, planType: "highfrequency", id: 401},
+		{minVCpus: 2, id: 203},
+		{minDisk: 100, id: 204},
+		{maxPrice: 5, id: 201},
+		{minRAM: 1024, minDisk: 60, id: 401},
+		{minVCpus: 4, maxPrice: 30, err: "No plan with at least 4 vCPUs, at most $30.00/month found in region ID 1. Closest plans: " +
+			"vc2-1c-1gb (ID 201, 1 vCPUs, 1024 MB RAM, 25 GB disk, $5.00/month): 1 vCPUs; " +
+			"vc2-1c-2gb (ID 202, 1 vCPUs, 2048 MB RAM, 55 GB disk, $10.00/month): 1 vCPUs; " +
+			"vhf-1c-2gb (ID 401, 1 vCPUs, 2048 MB RAM, 64 GB disk, $12.00/month): 1 vCPUs; " +
+			"vc2-2c-4gb (ID 203, 2 vCPUs, 4096 MB RAM, 80 GB disk, $20.00/month): 2 vCPUs; " +
+			"vc2-4c-8gb (ID 204, 4 vCPUs, 8192 MB RAM, 160 GB disk, $40.00/month): $40.00/month"},
+		{minVCpus: 8, err: "vc2-4c-8gb (ID 204, 4 vCPUs, 8192 MB RAM, 160 GB disk, $40.00/month): not available in the region, 4 vCPUs"},
+	}
+
+	for _, tt := range tests {
+		driver := NewDriver("default", "path")
+		driver.RegionID = 1
+		driver.MinVCpus = tt.minVCpus
+		driver.MinRAMMB = tt.minRAM
+		driver.MinDiskGB = tt.minDisk
+		driver.MaxMonthlyPrice = tt.maxPrice
+		driver.PlanType = tt.planType
+		assert.True(t, driver.hasPlanRequirements())
+
+		// plan 204 is sold out in the region for the last test
+		available := []int{201, 202, 203, 401, 204}
+		if tt.minVCpus == 8 {
+			available = available[:4]
+		}
+
+		plan, _, err := driver.selectPlan(testPricedPlans, available)
+		if tt.err != "" {
+			if assert.Error(t, err, tt.err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+			continue
+		}
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.id, plan.ID, driver.planRequirements())
+		}
+	}
+}
+
+func TestChooseRegionWithPlanRequirements(t *testing.T) {
+	driver, server := newTestDriver(map[string]string{
+		"/v1/plans/list": `{
+			"201":{"VPSPLANID":"201","vcpu_count":"1","ram":"1024","disk":"25","price_per_month":"5.00","plan_type":"SSD"},
+			"202":{"VPSPLANID":"202","vcpu_count":"1","ram":"2048","disk":"55","price_per_month":"10.00","plan_type":"SSD"},
+			"203":{"VPSPLANID":"203","vcpu_count":"2","ram":"4096","disk":"80","price_per_month":"20.00","plan_type":"SSD"}}`,
+		"/v1/regions/availability": `[201,203]`,
+	}, nil)
+	defer server.Close()
+
+	driver.RegionID = 1
+	driver.MinRAMMB = 2048
+	assert.NoError(t, driver.chooseRegion())
+	assert.Equal(t, 203, driver.PlanID)
+}
+
+func TestPlanRequirementFlags(t *testing.T) {
+	tests := []struct {
+		flags map[string]interface{}
+		err   string
+	}{
+		{flags: map[string]interface{}{"vultr-min-ram-mb": 2048, "vultr-max-monthly-price": "12.50"}},
+		{flags: map[string]interface{}{"vultr-max-monthly-price": "cheap"}, err: "Invalid --vultr-max-monthly-price 'cheap'. Must be a positive number"},
+		{flags: map[string]interface{}{"vultr-min-vcpus": -1}, err: "must not be negative"},
+		{flags: map[string]interface{}{"vultr-plan": "vc2-1c-1gb", "vultr-plan-type": "SSD"}, err: "--vultr-plan can't be combined"},
+	}
+
+	for _, tt := range tests {
+		driver := NewDriver("default", "path")
+		tt.flags["vultr-api-key"] = "APIKEY"
+		checkFlags := &drivers.CheckDriverOptions{
+			FlagsValues: tt.flags,
+			CreateFlags: driver.GetCreateFlags(),
+		}
+
+		err := driver.SetConfigFromFlags(checkFlags)
+		if tt.err != "" {
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, 2048, driver.MinRAMMB)
+		assert.Equal(t, 12.5, driver.MaxMonthlyPrice)
+	}
+}
@@ -0,0 +1,102 @@
+package vultr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const defaultOSProfile = "rancheros"
+
+// provisioningProfile provisions an operating system that is booted via
+// iPXE on the 'Custom OS' (OS ID 159).
+type provisioningProfile interface {
+	// Description names the operating system and version to provision
+	Description(d *Driver) string
+	// Validate checks the profile specific driver options
+	Validate(d *Driver) error
+	// SSHUser is the user docker-machine logs in as
+	SSHUser() string
+	// PXEScript generates the iPXE script that boots the operating system
+	PXEScript(d *Driver) string
+	// ValidateUserData checks a user data file passed with --vultr-userdata
+	ValidateUserData(path, content string) error
+	// UserData generates the user data of the VPS, including the rendered
+	// user data parts
+	UserData(d *Driver, parts []userDataPart) (string, error)
+	// PostCreate checks the operating system once the VPS is up
+	PostCreate(d *Driver) error
+}
+
+var provisioningProfiles = make(map[string]provisioningProfile)
+
+// registerProfile makes a provisioning profile available by name
+func registerProfile(name string, profile provisioningProfile) {
+	if _, ok := provisioningProfiles[name]; ok {
+		panic("provisioning profile registered twice: " + name)
+	}
+	provisioningProfiles[name] = profile
+}
+
+// profileNames returns the names of the registered provisioning profiles
+func profileNames() []string {
+	var names []string
+	for name := range provisioningProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profile returns the provisioning profile selected by --vultr-os-profile
+func (d *Driver) profile() (provisioningProfile, error) {
+	name := d.OSProfile
+	if name == "" {
+		name = defaultOSProfile
+	}
+
+	profile, ok := provisioningProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown OS profile '%s'. Available profiles: %s", name, strings.Join(profileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// runSSHCommand runs a command on the VPS, it is replaced in tests
+var runSSHCommand = drivers.RunSSHCommandFromDriver
+
+// checkOSRelease waits for SSH and verifies that the VPS booted the
+// operating system with the given os-release ID. A failed iPXE boot
+// otherwise only shows as a timeout of docker-machine. Failed connections
+// are retried until CreateTimeout expires, since the VPS may reboot after
+// installing the operating system on first boot.
+func checkOSRelease(d *Driver, id string) error {
+	log.Infof("Waiting for SSH to check the operating system...")
+	timeout := time.Duration(d.CreateTimeout) * time.Second
+
+	var out string
+	var sshErr error
+	err := waitFor(timeout, waitInitialInterval, waitMaxInterval, nil, func() (bool, error) {
+		out, sshErr = runSSHCommand(d, ". /etc/os-release && echo $ID")
+		if sshErr != nil {
+			log.Debugf("Unable to check the operating system, retrying: %v", sshErr)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err == errWaitTimeout {
+		return fmt.Errorf("Timed out after %s waiting for SSH to check the operating system: %v", timeout, sshErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if actual := strings.TrimSpace(out); actual != id {
+		return fmt.Errorf("VPS booted '%s' instead of '%s'. Check the PXE script ID %d", actual, id, d.PxeScriptID)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package vultr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfile(t *testing.T) {
+	assert.Equal(t, []string{"flatcar", "rancheros"}, profileNames())
+
+	driver := NewDriver("web-1", "")
+	profile, err := driver.profile()
+	assert.NoError(t, err)
+	assert.Equal(t, "rancher", profile.SSHUser())
+
+	driver.OSProfile = "flatcar"
+	profile, err = driver.profile()
+	assert.NoError(t, err)
+	assert.Equal(t, "core", profile.SSHUser())
+
+	driver.OSProfile = "coreos"
+	_, err = driver.profile()
+	assert.EqualError(t, err, "Unknown OS profile 'coreos'. Available profiles: flatcar, rancheros")
+}
+
+func TestProfileValidate(t *testing.T) {
+	driver := NewDriver("web-1", "")
+	driver.ROSVersion = defaultROSVersion
+	driver.FlatcarChannel = "edge"
+	driver.FlatcarVersion = defaultFlatcarVersion
+
+	assert.NoError(t, rancherOSProfile{}.Validate(driver))
+	assert.Error(t, flatcarProfile{}.Validate(driver))
+
+	driver.FlatcarChannel = defaultFlatcarChannel
+	assert.NoError(t, flatcarProfile{}.Validate(driver))
+}
+
+func TestProfileValidateUserData(t *testing.T) {
+	cloudConfig := "#cloud-config\nhostname: x\n"
+	ignition := `{"ignition":{"version":"3.3.0"}}`
+
+	assert.NoError(t, rancherOSProfile{}.ValidateUserData("a", cloudConfig))
+	assert.Error(t, rancherOSProfile{}.ValidateUserData("a", ignition))
+	assert.NoError(t, flatcarProfile{}.ValidateUserData("a", ignition))
+	assert.Error(t, flatcarProfile{}.ValidateUserData("a", cloudConfig))
+}
+
+func TestProfilePXEScript(t *testing.T) {
+	driver := NewDriver("web-1", "")
+	driver.ROSVersion = "v1.5.8"
+	driver.FlatcarChannel = "beta"
+	driver.FlatcarVersion = "3850.1.0"
+
+	assert.Contains(t, rancherOSProfile{}.PXEScript(driver), "http://releases.rancher.com/os/v1.5.8")
+	assert.Contains(t, flatcarProfile{}.PXEScript(driver), "https://beta.release.flatcar-linux.net/amd64-usr/3850.1.0")
+	assert.True(t, strings.HasPrefix(flatcarProfile{}.PXEScript(driver), "#!ipxe"))
+}
+
+func TestCheckOSRelease(t *testing.T) {
+	defer func(initial, max time.Duration) {
+		waitInitialInterval, waitMaxInterval = initial, max
+		runSSHCommand = drivers.RunSSHCommandFromDriver
+	}(waitInitialInterval, waitMaxInterval)
+	waitInitialInterval, waitMaxInterval = time.Millisecond, time.Millisecond
+
+	// the connection drops while the OS reboots after installing itself
+	attempts := 0
+	runSSHCommand = func(d drivers.Driver, command string) (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", errors.New("connection reset by peer")
+		}
+		return "rancheros\n", nil
+	}
+
+	driver := NewDriver("web-1", "")
+	driver.CreateTimeout = 5
+	assert.NoError(t, checkOSRelease(driver, "rancheros"))
+	assert.Equal(t, 3, attempts)
+
+	driver.PxeScriptID = 42
+	assert.EqualError(t, checkOSRelease(driver, "flatcar"), "VPS booted 'rancheros' instead of 'flatcar'. Check the PXE script ID 42")
+
+	runSSHCommand = func(d drivers.Driver, command string) (string, error) {
+		return "", errors.New("connection refused")
+	}
+	driver.CreateTimeout = 1
+	assert.EqualError(t, checkOSRelease(driver, "rancheros"), "Timed out after 1s waiting for SSH to check the operating system: connection refused")
+}
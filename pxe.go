@@ -0,0 +1,107 @@
+package vultr
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const ipxeHeader = "#!ipxe"
+
+// isURL reports whether --vultr-pxe-template is an iPXE chain URL
+// rather than a local file.
+func isURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
+
+// customPXE reports whether the VPS boots a PXE script supplied by the
+// user instead of the one of the provisioning profile.
+func (d *Driver) customPXE() bool {
+	return d.CustomPxeScript || d.PxeTemplate != "" || d.IPXEChainURL != ""
+}
+
+func parsePXETemplate(path string) (*template.Template, error) {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Unable to find PXE template at %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(string(buf), ipxeHeader) {
+		return nil, fmt.Errorf("PXE template %s must be an iPXE script starting with '%s'", path, ipxeHeader)
+	}
+
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(string(buf))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing PXE template %s: %v", path, err)
+	}
+	return tmpl, nil
+}
+
+// validatePXETemplate checks the options of a custom PXE boot
+func (d *Driver) validatePXETemplate() error {
+	if d.PxeTemplate == "" && d.IPXEChainURL == "" {
+		return nil
+	}
+
+	if d.OSID != 159 {
+		return fmt.Errorf("--vultr-pxe-template requires the 'Custom OS' (OS ID 159)")
+	}
+	if d.PxeScriptID != 0 {
+		return fmt.Errorf("--vultr-pxe-template and --vultr-pxe-script are mutually exclusive")
+	}
+
+	if d.PxeTemplate != "" {
+		if _, err := parsePXETemplate(d.PxeTemplate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderPXETemplate renders --vultr-pxe-template with the variables
+// available to user data templates.
+func (d *Driver) renderPXETemplate() (string, error) {
+	tmpl, err := parsePXETemplate(d.PxeTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	vars, err := d.templateVars()
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, vars); err != nil {
+		return "", fmt.Errorf("Error rendering PXE template %s: %v", d.PxeTemplate, err)
+	}
+	return buffer.String(), nil
+}
+
+// createPXETemplateScript renders --vultr-pxe-template and creates the PXE
+// script from it. The script of a previous attempt, rendered for another
+// region, is released first.
+func (d *Driver) createPXETemplateScript(tx *transaction) error {
+	if err := d.releasePXEScript(); err != nil {
+		return err
+	}
+
+	content, err := d.renderPXETemplate()
+	if err != nil {
+		return err
+	}
+	if err := d.createBootScript(tx, content); err != nil {
+		return err
+	}
+
+	log.Debugf("Created PXE script from %s: ID %d", d.PxeTemplate, d.PxeScriptID)
+	return nil
+}
@@ -0,0 +1,72 @@
+package vultr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPXETemplateFlag(t *testing.T) {
+	for _, tt := range []struct {
+		value, template, chainURL string
+	}{
+		{"boot.ipxe", "boot.ipxe", ""},
+		{"https://example.com/boot.ipxe", "", "https://example.com/boot.ipxe"},
+		{"", "", ""},
+	} {
+		driver := NewDriver("default", "path")
+		checkFlags := &drivers.CheckDriverOptions{
+			FlagsValues: map[string]interface{}{
+				"vultr-api-key":      "APIKEY",
+				"vultr-pxe-template": tt.value,
+			},
+			CreateFlags: driver.GetCreateFlags(),
+		}
+
+		assert.NoError(t, driver.SetConfigFromFlags(checkFlags))
+		assert.Equal(t, tt.template, driver.PxeTemplate)
+		assert.Equal(t, tt.chainURL, driver.IPXEChainURL)
+		assert.Equal(t, tt.value != "", driver.customPXE())
+	}
+}
+
+func TestRenderPXETemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-pxe")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "boot.ipxe")
+	content := "#!ipxe\nkernel http://example.com/vmlinuz hostname={{.MachineName}} role={{.Vars.role}}\nboot\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	driver := NewDriver("web-1", dir)
+	driver.VultrPublicKey = "ssh-rsa AAAA default"
+	driver.PxeTemplate = path
+	driver.UserDataVars = map[string]string{"role": "worker"}
+
+	assert.NoError(t, driver.validatePXETemplate())
+	script, err := driver.renderPXETemplate()
+	assert.NoError(t, err)
+	assert.Equal(t, "#!ipxe\nkernel http://example.com/vmlinuz hostname=web-1 role=worker\nboot\n", script)
+
+	driver.UserDataVars = nil
+	_, err = driver.renderPXETemplate()
+	assert.Error(t, err)
+
+	driver.PxeScriptID = 42
+	assert.EqualError(t, driver.validatePXETemplate(), "--vultr-pxe-template and --vultr-pxe-script are mutually exclusive")
+
+	driver.PxeScriptID = 0
+	driver.OSID = 215
+	assert.Error(t, driver.validatePXETemplate())
+
+	driver.OSID = 159
+	assert.NoError(t, ioutil.WriteFile(path, []byte("kernel http://example.com/vmlinuz\n"), 0600))
+	assert.Error(t, driver.validatePXETemplate())
+}
@@ -0,0 +1,137 @@
+package vultr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+func init() {
+	registerProfile("rancheros", rancherOSProfile{})
+}
+
+// rancherOSProfile provisions RancherOS, configured by cloud-config. The
+// generated cloud-config is also used with a custom PXE script.
+type rancherOSProfile struct{}
+
+func (rancherOSProfile) Description(d *Driver) string {
+	return fmt.Sprintf("RancherOS (%s)", d.ROSVersion)
+}
+
+func (rancherOSProfile) Validate(d *Driver) error {
+	if d.ROSVersion == "" {
+		return fmt.Errorf("--vultr-ros-version must not be empty")
+	}
+	return nil
+}
+
+func (rancherOSProfile) SSHUser() string {
+	return "rancher"
+}
+
+func (rancherOSProfile) PXEScript(d *Driver) string {
+	return rancherOSPXEScript(d.ROSVersion)
+}
+
+func (rancherOSProfile) ValidateUserData(path, content string) error {
+	if !isCloudConfig(content) {
+		return fmt.Errorf("--vultr-userdata must be a cloud-config file (starting with '%s') when using the 'Custom OS' (OS ID 159): %s", cloudConfigHeader, path)
+	}
+	return nil
+}
+
+// UserData merges the user's cloud-config files into the generated cloud-config
+func (rancherOSProfile) UserData(d *Driver, parts []userDataPart) (string, error) {
+	userdata, err := d.getCloudConfig()
+	if err != nil {
+		return "", err
+	}
+	for _, part := range parts {
+		userdata, err = mergeCloudConfig(userdata, part.Content)
+		if err != nil {
+			return "", err
+		}
+	}
+	return userdata, nil
+}
+
+func (rancherOSProfile) PostCreate(d *Driver) error {
+	return checkOSRelease(d, "rancheros")
+}
+
+// RancherOS - Generate iPXE script
+func rancherOSPXEScript(version string) string {
+	content := `#!ipxe
+set base-url http://releases.rancher.com/os/%s
+kernel ${base-url}/vmlinuz rancher.state.dev=LABEL=RANCHER_STATE rancher.state.autoformat=[/dev/vda] rancher.state.formatzero rancher.cloud_init.datasources=[ec2]
+initrd ${base-url}/initrd
+boot`
+
+	return fmt.Sprintf(content, version)
+}
+
+// RancherOS - Generate cloud-config userdata string that will
+// provision the SSH Key to the VPS and configure private networking
+func (d *Driver) getCloudConfig() (string, error) {
+	type userData struct {
+		HostName       string
+		SSHkey         string
+		AuthorizedKeys []string
+		PrivateNet     bool
+		CustomScript   bool
+	}
+
+	const tpl = `#cloud-config
+hostname: {{.HostName}}
+ssh_authorized_keys:
+  - {{.SSHkey}}{{range .AuthorizedKeys}}
+  - {{.}}{{end}}{{if not .CustomScript}}
+write_files:
+  - path: /opt/rancher/bin/start.sh
+    permissions: "0755"
+    owner: root
+    content: |
+      #!/bin/sh
+      mount | grep /dev/vda >/dev/null
+      RETVAL=$?
+      if [ $RETVAL -eq 0 ]; then
+        exit 0
+      fi
+      sudo dd if=/dev/zero of=/dev/vda bs=1M count=1
+      logger -t start.sh "Prepared /dev/vda for use as Rancher state disk. Rebooting."
+      sudo reboot
+rancher:
+  network:
+    interfaces:
+      eth0:
+        dhcp: true{{if .PrivateNet}}
+      eth1:
+        address: $private_ipv4/16
+        mtu: 1450{{end}}{{end}}
+`
+	var buffer bytes.Buffer
+	publicKey, err := d.publicKey()
+	if err != nil {
+		return "", err
+	}
+
+	config := userData{
+		HostName:       d.MachineName,
+		SSHkey:         strings.TrimSpace(publicKey),
+		AuthorizedKeys: d.AuthorizedKeys,
+		PrivateNet:     d.PrivateNetworking,
+		CustomScript:   d.customPXE(),
+	}
+	tmpl, err := template.New("cloud-config").Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+
+	err = tmpl.Execute(&buffer, config)
+	if err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
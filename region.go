@@ -0,0 +1,117 @@
+package vultr
+
+import (
+	"fmt"
+	"strings"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// regionChoice is a region where the VPS can be created together with
+// the plan to use there.
+type regionChoice struct {
+	RegionID int
+	PlanID   int
+}
+
+// regionBound reports whether resources tied to the first region, like
+// block storage volumes or reserved IPs, prevent falling back to others.
+func (d *Driver) regionBound() bool {
+	return len(d.BlockStorage) > 0 || d.BlockStorageSize > 0 || d.ReservedIP != "" || d.CreateReservedIP
+}
+
+// chooseRegion picks the first of the regions given by --vultr-region
+// where the plan is available, or the cheapest plan meeting the
+// requirements. The other usable regions are kept in order, so Create can
+// fall back to them if the chosen region runs out of capacity.
+func (d *Driver) chooseRegion() error {
+	client := d.getClient()
+	regionIDs := d.regionIDs
+	if len(regionIDs) == 0 {
+		regionIDs = []int{d.RegionID}
+	}
+
+	var plans []vultr.Plan
+	if d.hasPlanRequirements() {
+		var err error
+		if plans, err = client.GetPlans(); err != nil {
+			return err
+		}
+	}
+
+	planID := d.PlanID
+	var choices []regionChoice
+	var skipped []string
+	for _, id := range regionIDs {
+		available, err := client.GetAvailablePlansForRegion(id)
+		if err != nil {
+			return err
+		}
+
+		d.RegionID = id
+		plan, matches, err := d.planForRegion(plans, available)
+		if err != nil {
+			if len(regionIDs) == 1 {
+				return err
+			}
+			log.Debugf("Skipping region ID %d: %v", id, err)
+			skipped = append(skipped, err.Error())
+			continue
+		}
+
+		choice := regionChoice{RegionID: id, PlanID: planID}
+		if plan != nil {
+			choice.PlanID = plan.ID
+			if len(choices) == 0 {
+				log.Infof("Using plan %s, the cheapest of %d plans in region ID %d with %s", planLabel(*plan), matches, id, d.planRequirements())
+			}
+		}
+		choices = append(choices, choice)
+	}
+
+	if len(choices) == 0 {
+		return fmt.Errorf("None of the regions %v can host the VPS: %s", regionIDs, strings.Join(skipped, "; "))
+	}
+
+	if len(choices) > 1 && d.regionBound() {
+		log.Infof("Block storage and reserved IPs are bound to region ID %d, not falling back to other regions", choices[0].RegionID)
+		choices = choices[:1]
+	}
+
+	d.RegionID, d.PlanID = choices[0].RegionID, choices[0].PlanID
+	d.regionFallbacks = choices[1:]
+	if len(regionIDs) > 1 {
+		log.Infof("Using region ID %d", d.RegionID)
+	}
+	return nil
+}
+
+// createServer creates the VPS in RegionID. If the region is out of
+// capacity, it is retried in the fallback regions chosen by chooseRegion.
+// RegionID and PlanID are updated to the region the VPS was created in,
+// and the user data and PXE template are rendered again for it, since
+// templates can use them.
+func (d *Driver) createServer(tx *transaction, options *vultr.ServerOptions) (vultr.Server, error) {
+	client := d.getClient()
+	for {
+		server, err := client.CreateServer(d.MachineName, d.RegionID, d.PlanID, d.OSID, options)
+		if err == nil || !vultr.IsCapacityError(err) || len(d.regionFallbacks) == 0 {
+			return server, err
+		}
+
+		next := d.regionFallbacks[0]
+		d.regionFallbacks = d.regionFallbacks[1:]
+		log.Infof("Region ID %d can't host the VPS (%v), retrying in region ID %d", d.RegionID, err, next.RegionID)
+		d.RegionID, d.PlanID = next.RegionID, next.PlanID
+		if options.UserData, err = d.buildUserData(); err != nil {
+			return server, err
+		}
+		if d.PxeTemplate != "" {
+			if err := d.createPXETemplateScript(tx); err != nil {
+				return server, err
+			}
+			options.Script = d.PxeScriptID
+		}
+	}
+}
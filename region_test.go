@@ -0,0 +1,218 @@
+package vultr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRegionTestDriver returns a driver whose API only has plan 201
+// available in the given regions and reports the regions in soldOut as
+// out of capacity when creating servers.
+func newRegionTestDriver(available, soldOut []int, created *[]string) (*Driver, *httptest.Server) {
+	driver, server := newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/regions/availability":
+			var id int
+			fmt.Sscan(r.URL.Query().Get("DCID"), &id)
+			if containsInt(available, id) {
+				fmt.Fprint(w, `[200,201]`)
+			} else {
+				fmt.Fprint(w, `[200]`)
+			}
+		case "/v1/server/create":
+			r.ParseForm()
+			*created = append(*created, r.Form.Get("DCID"))
+			var id int
+			fmt.Sscan(r.Form.Get("DCID"), &id)
+			if containsInt(soldOut, id) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, "Plan is not available in the selected datacenter.  This could mean you have chosen the wrong plan (for example, a storage plan in a location that does not offer them), or the location you have selected does not have any more capacity.")
+				return
+			}
+			fmt.Fprint(w, `{"SUBID":"576965"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	driver.PlanID = 201
+	return driver, server
+}
+
+func TestChooseRegion(t *testing.T) {
+	driver, server := newRegionTestDriver([]int{9, 12}, nil, nil)
+	defer server.Close()
+
+	driver.regionIDs = []int{7, 9, 1, 12}
+	assert.NoError(t, driver.chooseRegion())
+	assert.Equal(t, 9, driver.RegionID)
+	assert.Equal(t, []regionChoice{{RegionID: 12, PlanID: 201}}, driver.regionFallbacks)
+
+	driver.regionIDs = []int{9, 12}
+	driver.CreateReservedIP = true
+	assert.NoError(t, driver.chooseRegion())
+	assert.Equal(t, 9, driver.RegionID)
+	assert.Empty(t, driver.regionFallbacks)
+
+	driver.regionIDs = []int{7, 1}
+	err := driver.chooseRegion()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "None of the regions [7 1] can host the VPS: PlanID 201 not available in the chosen region. Available plans for RegionID 7: [200]")
+	}
+
+	driver.regionIDs = nil
+	driver.RegionID = 7
+	assert.EqualError(t, driver.chooseRegion(), "PlanID 201 not available in the chosen region. Available plans for RegionID 7: [200]")
+}
+
+func TestCreateServerFallback(t *testing.T) {
+	var created []string
+	driver, server := newRegionTestDriver([]int{7, 9, 12}, []int{7, 9}, &created)
+	defer server.Close()
+
+	driver.VultrPublicKey = "ssh-rsa AAAA default"
+	driver.regionIDs = []int{7, 9, 12}
+	assert.NoError(t, driver.chooseRegion())
+
+	machine, err := driver.createServer(&transaction{}, &vultr.ServerOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "576965", machine.ID)
+	assert.Equal(t, []string{"7", "9", "12"}, created)
+	assert.Equal(t, 12, driver.RegionID)
+
+	// without fallbacks the capacity error is returned
+	created = nil
+	driver.RegionID = 7
+	_, err = driver.createServer(&transaction{}, &vultr.ServerOptions{})
+	assert.True(t, vultr.IsCapacityError(err))
+	assert.Equal(t, []string{"7"}, created)
+}
+
+func TestCreateServerFallbackUserData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-region")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// regions 7 and 9 are out of capacity
+	var userdata []string
+	driver, server := newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/regions/availability":
+			fmt.Fprint(w, `[201]`)
+		case "/v1/server/create":
+			r.ParseForm()
+			buf, _ := base64.StdEncoding.DecodeString(r.Form.Get("userdata"))
+			userdata = append(userdata, string(buf))
+			if r.Form.Get("DCID") != "12" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, "Plan is not available in the selected datacenter.  This could mean you have chosen the wrong plan (for example, a storage plan in a location that does not offer them), or the location you have selected does not have any more capacity.")
+				return
+			}
+			fmt.Fprint(w, `{"SUBID":"576965"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	path := filepath.Join(dir, "userdata.sh")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\necho region={{.RegionID}} plan={{.PlanID}}\n"), 0600))
+
+	driver.VultrPublicKey = "ssh-rsa AAAA default"
+	driver.OSID = 215
+	driver.PlanID = 201
+	driver.UserDataFiles = []string{path}
+	driver.regionIDs = []int{7, 9, 12}
+	assert.NoError(t, driver.chooseRegion())
+
+	first, err := driver.buildUserData()
+	assert.NoError(t, err)
+	_, err = driver.createServer(&transaction{}, &vultr.ServerOptions{UserData: first})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"#!/bin/sh\necho region=7 plan=201\n",
+		"#!/bin/sh\necho region=9 plan=201\n",
+		"#!/bin/sh\necho region=12 plan=201\n",
+	}, userdata)
+}
+
+func TestCreateServerFallbackPXETemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-region")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// the API keeps the startup scripts, regions 7 and 9 are out of capacity
+	scripts := make(map[string]vultr.StartupScript)
+	var scriptIDs []string
+	nextID := 1
+	driver, server := newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/v1/regions/availability":
+			fmt.Fprint(w, `[201]`)
+		case "/v1/startupscript/list":
+			json.NewEncoder(w).Encode(scripts)
+		case "/v1/startupscript/create":
+			id := strconv.Itoa(nextID)
+			nextID++
+			scripts[id] = vultr.StartupScript{ID: id, Name: r.Form.Get("name"), Type: r.Form.Get("type"), Content: r.Form.Get("script")}
+			fmt.Fprintf(w, `{"SCRIPTID":%s}`, id)
+		case "/v1/startupscript/update":
+			script := scripts[r.Form.Get("SCRIPTID")]
+			script.Name = r.Form.Get("name")
+			scripts[script.ID] = script
+		case "/v1/startupscript/destroy":
+			delete(scripts, r.Form.Get("SCRIPTID"))
+		case "/v1/server/create":
+			scriptIDs = append(scriptIDs, r.Form.Get("SCRIPTID"))
+			if r.Form.Get("DCID") != "12" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, "Plan is not available in the selected datacenter.  This could mean you have chosen the wrong plan (for example, a storage plan in a location that does not offer them), or the location you have selected does not have any more capacity.")
+				return
+			}
+			fmt.Fprint(w, `{"SUBID":"576965"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	template := filepath.Join(dir, "boot.ipxe")
+	assert.NoError(t, ioutil.WriteFile(template, []byte("#!ipxe\nchain http://example.com/{{.RegionID}}/boot.ipxe\n"), 0600))
+
+	driver.VultrPublicKey = "ssh-rsa AAAA default"
+	driver.OSID = 159
+	driver.PlanID = 201
+	driver.PxeTemplate = template
+	driver.regionIDs = []int{7, 9, 12}
+	assert.NoError(t, driver.chooseRegion())
+
+	var tx transaction
+	assert.NoError(t, driver.createPXETemplateScript(&tx))
+	_, err = driver.createServer(&tx, &vultr.ServerOptions{Script: driver.PxeScriptID})
+	assert.NoError(t, err)
+
+	// each attempt boots a script rendered for its region, the scripts of
+	// the failed attempts are deleted
+	assert.Equal(t, []string{"1", "2", "3"}, scriptIDs)
+	if assert.Len(t, scripts, 1) {
+		assert.Equal(t, "#!ipxe\nchain http://example.com/12/boot.ipxe\n", scripts["3"].Content)
+		assert.Equal(t, scriptName("pxe", scripts["3"].Content)+": default", scripts["3"].Name)
+	}
+	assert.Equal(t, 3, driver.PxeScriptID)
+}
@@ -0,0 +1,107 @@
+package vultr
+
+import (
+	"fmt"
+	"net"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// resolveReservedIP accepts either the address or the ID of an existing
+// reserved IP and stores the address, which is what the API expects when
+// creating the VPS.
+func (d *Driver) resolveReservedIP() error {
+	if d.ReservedIP == "" || net.ParseIP(d.ReservedIP) != nil {
+		return nil
+	}
+
+	ip, err := d.getClient().GetReservedIP(d.ReservedIP)
+	if err != nil {
+		return err
+	}
+	if ip.RegionID != d.RegionID {
+		return fmt.Errorf("Reserved IP %s is located in region ID %d, not in region ID %d", d.ReservedIP, ip.RegionID, d.RegionID)
+	}
+	if ip.AttachedTo != "" && ip.AttachedTo != "0" {
+		return fmt.Errorf("Reserved IP %s is already attached to VPS %s", d.ReservedIP, ip.AttachedTo)
+	}
+
+	log.Debugf("Resolved reserved IP ID %s to %s", d.ReservedIP, ip.Subnet)
+	d.ReservedIPID = ip.ID
+	d.ReservedIP = ip.Subnet
+	return nil
+}
+
+// createReservedIP reserves a new IPv4 address in the region of the VPS.
+// It becomes the main IP of the VPS.
+func (d *Driver) createReservedIP(tx *transaction) error {
+	client := d.getClient()
+	id, err := client.CreateReservedIP(d.RegionID, "v4", d.MachineName)
+	if err != nil {
+		return err
+	}
+	d.ReservedIPID = id
+	d.ReservedIPCreated = true
+	tx.record("reserved IP "+id, d.destroyReservedIP)
+
+	ip, err := client.GetReservedIP(id)
+	if err != nil {
+		return err
+	}
+	d.ReservedIP = ip.Subnet
+	log.Infof("Created reserved IP %s (ID %s)", d.ReservedIP, id)
+
+	return nil
+}
+
+// destroyReservedIP deletes the reserved IP created by createReservedIP.
+func (d *Driver) destroyReservedIP() error {
+	if d.ReservedIPID == "" {
+		return nil
+	}
+	if err := d.getClient().DestroyReservedIP(d.ReservedIPID); err != nil {
+		return err
+	}
+	d.ReservedIPID = ""
+	d.ReservedIP = ""
+	d.ReservedIPCreated = false
+	return nil
+}
+
+// detachReservedIP detaches the reserved IP created by the driver so it
+// survives the deletion of the VPS. Reserved IPs that aren't retained are
+// left attached and destroyed by removeReservedIP once the VPS is gone.
+func (d *Driver) detachReservedIP() error {
+	if !d.ReservedIPCreated || !d.ReservedIPRetain || d.ReservedIP == "" || d.MachineID == "" {
+		return nil
+	}
+
+	log.Infof("Detaching reserved IP %s", d.ReservedIP)
+	if err := d.getClient().DetachReservedIP(d.MachineID, d.ReservedIP); err != nil && !vultr.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// removeReservedIP keeps or destroys the reserved IP created by the driver,
+// according to --vultr-reserved-ip-retain.
+func (d *Driver) removeReservedIP() error {
+	if !d.ReservedIPCreated || d.ReservedIPID == "" {
+		return nil
+	}
+
+	if d.ReservedIPRetain {
+		log.Infof("Keeping reserved IP %s. Use --vultr-reserved-ip=%s to assign it to a new machine.", d.ReservedIP, d.ReservedIPID)
+		return nil
+	}
+
+	log.Infof("Destroying reserved IP %s", d.ReservedIP)
+	if err := d.destroyReservedIP(); err != nil {
+		if !vultr.IsNotFound(err) {
+			return err
+		}
+		log.Infof("Reserved IP doesn't exist, assuming it is already deleted")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package vultr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testReservedIPList = `{"1313044":{"SUBID":1313044,"DCID":1,"ip_type":"v4","subnet":"10.234.22.53","subnet_size":32,"label":"my first reserved ip","attached_SUBID":false}}`
+
+func TestResolveReservedIP(t *testing.T) {
+	driver, server := newTestDriver(map[string]string{
+		"/v1/reservedip/list": testReservedIPList,
+	}, nil)
+	defer server.Close()
+
+	driver.ReservedIP = "1313044"
+	assert.NoError(t, driver.resolveReservedIP())
+	assert.Equal(t, "10.234.22.53", driver.ReservedIP)
+	assert.Equal(t, "1313044", driver.ReservedIPID)
+
+	driver.ReservedIP = "10.234.22.53"
+	assert.NoError(t, driver.resolveReservedIP())
+	assert.Equal(t, "10.234.22.53", driver.ReservedIP)
+
+	driver.ReservedIP = "1313044"
+	driver.RegionID = 7
+	assert.Error(t, driver.resolveReservedIP())
+}
+
+func TestRemoveReservedIP(t *testing.T) {
+	var calls []string
+	driver, server := newTestDriver(map[string]string{
+		"/v1/reservedip/destroy": "",
+	}, &calls)
+	defer server.Close()
+
+	driver.ReservedIPCreated = true
+	driver.ReservedIPID = "1313044"
+	driver.ReservedIP = "10.234.22.53"
+
+	driver.ReservedIPRetain = true
+	assert.NoError(t, driver.removeReservedIP())
+	assert.Empty(t, calls)
+	assert.Equal(t, "1313044", driver.ReservedIPID)
+
+	driver.ReservedIPRetain = false
+	assert.NoError(t, driver.removeReservedIP())
+	assert.Equal(t, []string{"/v1/reservedip/destroy"}, calls)
+	assert.Empty(t, driver.ReservedIPID)
+}
+
+func TestDetachReservedIP(t *testing.T) {
+	var calls []string
+	driver, server := newTestDriver(map[string]string{
+		"/v1/reservedip/detach": "",
+	}, &calls)
+	defer server.Close()
+
+	driver.MachineID = "576965"
+	driver.ReservedIPCreated = true
+	driver.ReservedIPID = "1313044"
+	driver.ReservedIP = "10.234.22.53"
+
+	// the IP is destroyed after the VPS, so it stays attached
+	assert.NoError(t, driver.detachReservedIP())
+	assert.Empty(t, calls)
+
+	driver.ReservedIPRetain = true
+	assert.NoError(t, driver.detachReservedIP())
+	assert.Equal(t, []string{"/v1/reservedip/detach"}, calls)
+}
@@ -0,0 +1,281 @@
+package vultr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+)
+
+const maxSuggestions = 5
+
+// candidate is a named Vultr resource that a user supplied
+// value can be resolved against.
+type candidate struct {
+	ID    int
+	Label string
+	Keys  []string
+}
+
+// resolveRegion looks up the ID of the region identified by query. The query
+// may be the numeric DCID, the region code (e.g. 'ams') or the region name.
+func resolveRegion(regions []vultr.Region, query string) (int, error) {
+	var candidates []candidate
+	for _, r := range regions {
+		candidates = append(candidates, candidate{
+			ID:    r.ID,
+			Label: fmt.Sprintf("%s (%s, ID %d)", r.Name, r.Code, r.ID),
+			Keys:  []string{r.Code, r.Name},
+		})
+	}
+	return resolve("region", candidates, query)
+}
+
+// resolveRegions looks up the IDs of the regions identified by query, in
+// order of preference. The query is either a comma separated list of
+// values understood by resolveRegion, or a filter of the form
+// 'continent:<name>' or 'country:<code>' (e.g. 'continent:Europe',
+// 'country:DE').
+func resolveRegions(regions []vultr.Region, query string) ([]int, error) {
+	for _, filter := range []string{"continent", "country"} {
+		prefix := filter + ":"
+		if !strings.HasPrefix(strings.ToLower(query), prefix) {
+			continue
+		}
+		value := strings.TrimSpace(query[len(prefix):])
+
+		var ids []int
+		var known []string
+		for _, r := range regions {
+			field := r.Continent
+			if filter == "country" {
+				field = r.Country
+			}
+			if strings.EqualFold(field, value) {
+				ids = append(ids, r.ID)
+			}
+			if field != "" && !containsString(known, field) {
+				known = append(known, field)
+			}
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("No region in %s '%s'. Available: %s", filter, value, strings.Join(known, ", "))
+		}
+		return ids, nil
+	}
+
+	var ids []int
+	for _, value := range strings.Split(query, ",") {
+		id, err := resolveRegion(regions, value)
+		if err != nil {
+			return nil, err
+		}
+		if !containsInt(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePlan looks up the ID of the plan identified by query. The query
+// may be the numeric VPSPLANID, the plan name or a slug of the form
+// '<type>-<vcpus>c-<ram>gb' (e.g. 'vc2-1c-1gb' or 'vhf-1c-2gb').
+func resolvePlan(plans []vultr.Plan, query string) (int, error) {
+	var candidates []candidate
+	for _, p := range plans {
+		slug := planSlug(p)
+		candidates = append(candidates, candidate{
+			ID:    p.ID,
+			Label: fmt.Sprintf("%s (%s, ID %d)", slug, p.Name, p.ID),
+			Keys:  []string{slug, p.Name},
+		})
+	}
+	return resolve("plan", candidates, query)
+}
+
+// resolveOS looks up the ID of the operating system identified by query.
+// The query may be the numeric OSID or the OS name (e.g. 'Ubuntu 16.04 x64').
+func resolveOS(oses []vultr.OS, query string) (int, error) {
+	var candidates []candidate
+	for _, o := range oses {
+		candidates = append(candidates, candidate{
+			ID:    o.ID,
+			Label: fmt.Sprintf("%s (ID %d)", o.Name, o.ID),
+			Keys:  []string{o.Name},
+		})
+	}
+	return resolve("OS", candidates, query)
+}
+
+// planTypePrefixes maps plan types to the prefix of their slugs, as used
+// by Vultr for the plan IDs of its v2 API.
+var planTypePrefixes = map[string]string{
+	"":              "vc2",
+	"SSD":           "vc2",
+	"HIGHFREQUENCY": "vhf",
+	"DEDICATED":     "vdc",
+}
+
+// planSlug returns a short, human-readable identifier for a plan
+// derived from its type, vCPU count and amount of RAM.
+func planSlug(p vultr.Plan) string {
+	ram, err := strconv.ParseFloat(strings.TrimSpace(p.RAM), 64)
+	if err != nil {
+		return ""
+	}
+	prefix, ok := planTypePrefixes[strings.ToUpper(p.Type)]
+	if !ok {
+		prefix = strings.ToLower(p.Type)
+	}
+	return fmt.Sprintf("%s-%dc-%sgb", prefix, p.VCpus, strconv.FormatFloat(ram/1024, 'f', -1, 64))
+}
+
+// resolve matches query against the given candidates. Numeric IDs and
+// exact (case-insensitive) key matches win. Otherwise a single candidate
+// with a key containing the query is accepted. Ambiguous or unknown values
+// result in an error listing the closest candidates.
+func resolve(kind string, candidates []candidate, query string) (int, error) {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return 0, fmt.Errorf("No %s specified", kind)
+	}
+
+	if id, err := strconv.Atoi(q); err == nil {
+		for _, c := range candidates {
+			if c.ID == id {
+				return id, nil
+			}
+		}
+	}
+
+	var exact, partial []candidate
+	for _, c := range candidates {
+		for _, key := range c.Keys {
+			k := strings.ToLower(key)
+			if k == "" {
+				continue
+			}
+			if k == q {
+				exact = append(exact, c)
+				break
+			}
+			if strings.Contains(k, q) {
+				partial = append(partial, c)
+				break
+			}
+		}
+	}
+
+	matches := exact
+	if len(matches) == 0 {
+		matches = partial
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0].ID, nil
+	case 0:
+		suggestions := suggest(candidates, q)
+		if len(suggestions) == 0 {
+			return 0, fmt.Errorf("Unknown %s '%s'", kind, query)
+		}
+		return 0, fmt.Errorf("Unknown %s '%s'. Did you mean: %s", kind, query, labels(suggestions))
+	default:
+		return 0, fmt.Errorf("Ambiguous %s '%s'. Matching: %s", kind, query, labels(matches))
+	}
+}
+
+// suggest returns up to maxSuggestions candidates whose keys are within a
+// small edit distance of the query, closest first.
+func suggest(candidates []candidate, query string) []candidate {
+	threshold := len(query)/2 + 1
+	var result []candidate
+	var distances []int
+	for _, c := range candidates {
+		best := -1
+		for _, key := range c.Keys {
+			d := levenshtein(strings.ToLower(key), query)
+			if best == -1 || d < best {
+				best = d
+			}
+		}
+		if best == -1 || best > threshold {
+			continue
+		}
+
+		// insertion sort keeps the closest candidates first
+		i := len(result)
+		result = append(result, c)
+		distances = append(distances, best)
+		for ; i > 0 && distances[i-1] > best; i-- {
+			result[i], distances[i] = result[i-1], distances[i-1]
+		}
+		result[i], distances[i] = c, best
+	}
+
+	if len(result) > maxSuggestions {
+		result = result[:maxSuggestions]
+	}
+	return result
+}
+
+func labels(candidates []candidate) string {
+	var l []string
+	for _, c := range candidates {
+		l = append(l, c.Label)
+	}
+	return strings.Join(l, ", ")
+}
+
+// levenshtein returns the edit distance between the strings a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,146 @@
+package vultr
+
+import (
+	"testing"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+var testRegions = []vultr.Region{
+	{ID: 1, Name: "New Jersey", Code: "EWR", Country: "US", Continent: "North America"},
+	{ID: 7, Name: "Amsterdam", Code: "AMS", Country: "NL", Continent: "Europe"},
+	{ID: 9, Name: "Frankfurt", Code: "FRA", Country: "DE", Continent: "Europe"},
+	{ID: 12, Name: "Silicon Valley", Code: "SJC", Country: "US", Continent: "North America"},
+	{ID: 39, Name: "Miami", Code: "MIA", Country: "US", Continent: "North America"},
+}
+
+var testPlans = []vultr.Plan{
+	{ID: 200, Name: "512 MB RAM,20 GB SSD,0.50 TB BW", VCpus: 1, RAM: "512"},
+	{ID: 201, Name: "1024 MB RAM,25 GB SSD,1.00 TB BW", VCpus: 1, RAM: "1024"},
+	{ID: 202, Name: "2048 MB RAM,40 GB SSD,2.00 TB BW", VCpus: 1, RAM: "2048"},
+	{ID: 203, Name: "4096 MB RAM,60 GB SSD,3.00 TB BW", VCpus: 2, RAM: "4096"},
+	{ID: 401, Name: "1024 MB RAM,32 GB SSD,1.00 TB BW", VCpus: 1, RAM: "1024", Type: "HIGHFREQUENCY"},
+	{ID: 115, Name: "8192 MB RAM,110 GB SSD,10.00 TB BW", VCpus: 2, RAM: "8192", Type: "DEDICATED"},
+}
+
+var testOSes = []vultr.OS{
+	{ID: 159, Name: "Custom"},
+	{ID: 164, Name: "Snapshot"},
+	{ID: 215, Name: "Ubuntu 16.04 x64"},
+	{ID: 216, Name: "Ubuntu 16.04 i386"},
+	{ID: 270, Name: "Ubuntu 18.04 x64"},
+}
+
+func TestResolveRegion(t *testing.T) {
+	tests := []struct {
+		query string
+		id    int
+		err   string
+	}{
+		{query: "7", id: 7},
+		{query: "ams", id: 7},
+		{query: "AMS", id: 7},
+		{query: "frankfurt", id: 9},
+		{query: "silicon", id: 12},
+		{query: "er", err: "Ambiguous"},
+		{query: "amsterdm", err: "Did you mean: Amsterdam (AMS, ID 7)"},
+		{query: "tokyo", err: "Unknown region 'tokyo'"},
+	}
+
+	for _, tt := range tests {
+		id, err := resolveRegion(testRegions, tt.query)
+		if tt.err != "" {
+			if assert.Error(t, err, tt.query) {
+				assert.Contains(t, err.Error(), tt.err, tt.query)
+			}
+			continue
+		}
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.id, id, tt.query)
+	}
+}
+
+func TestResolveRegions(t *testing.T) {
+	tests := []struct {
+		query string
+		ids   []int
+		err   string
+	}{
+		{query: "ams", ids: []int{7}},
+		{query: "fra, ams,7", ids: []int{9, 7}},
+		{query: "continent:europe", ids: []int{7, 9}},
+		{query: "country:US", ids: []int{1, 12, 39}},
+		{query: "continent:Asia", err: "No region in continent 'Asia'. Available: North America, Europe"},
+		{query: "ams,tokyo", err: "Unknown region 'tokyo'"},
+	}
+
+	for _, tt := range tests {
+		ids, err := resolveRegions(testRegions, tt.query)
+		if tt.err != "" {
+			if assert.Error(t, err, tt.query) {
+				assert.Contains(t, err.Error(), tt.err, tt.query)
+			}
+			continue
+		}
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.ids, ids, tt.query)
+	}
+}
+
+func TestResolvePlan(t *testing.T) {
+	tests := []struct {
+		query string
+		id    int
+		err   string
+	}{
+		{query: "201", id: 201},
+		{query: "vc2-1c-0.5gb", id: 200},
+		{query: "vc2-1c-1gb", id: 201},
+		{query: "vhf-1c-1gb", id: 401},
+		{query: "vdc-2c-8gb", id: 115},
+		{query: "VC2-2C-4GB", id: 203},
+		{query: "2048 MB RAM,40 GB SSD,2.00 TB BW", id: 202},
+		{query: "vc2-1c", err: "Ambiguous"},
+		{query: "vc2-1c-3gb", err: "Did you mean"},
+	}
+
+	for _, tt := range tests {
+		id, err := resolvePlan(testPlans, tt.query)
+		if tt.err != "" {
+			if assert.Error(t, err, tt.query) {
+				assert.Contains(t, err.Error(), tt.err, tt.query)
+			}
+			continue
+		}
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.id, id, tt.query)
+	}
+}
+
+func TestResolveOS(t *testing.T) {
+	tests := []struct {
+		query string
+		id    int
+		err   string
+	}{
+		{query: "215", id: 215},
+		{query: "Ubuntu 16.04 x64", id: 215},
+		{query: "ubuntu 18.04", id: 270},
+		{query: "custom", id: 159},
+		{query: "Ubuntu 16.04", err: "Ambiguous"},
+		{query: "Ubuntu 16.04 x86", err: "Did you mean: Ubuntu 16.04 x64 (ID 215)"},
+	}
+
+	for _, tt := range tests {
+		id, err := resolveOS(testOSes, tt.query)
+		if tt.err != "" {
+			if assert.Error(t, err, tt.query) {
+				assert.Contains(t, err.Error(), tt.err, tt.query)
+			}
+			continue
+		}
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.id, id, tt.query)
+	}
+}
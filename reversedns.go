@@ -0,0 +1,107 @@
+package vultr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// reverseDNSData holds the variables available to the reverse DNS template
+type reverseDNSData struct {
+	MachineName string
+	RegionID    int
+	IP          string
+}
+
+// renderReverseDNS renders the reverse DNS hostname template for ip.
+func (d *Driver) renderReverseDNS(ip string) (string, error) {
+	tmpl, err := template.New("reverse-dns").Option("missingkey=error").Parse(d.ReverseDNS)
+	if err != nil {
+		return "", fmt.Errorf("Invalid reverse DNS template: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	data := reverseDNSData{MachineName: d.MachineName, RegionID: d.RegionID, IP: ip}
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("Invalid reverse DNS template: %v", err)
+	}
+
+	hostname := strings.TrimSpace(buffer.String())
+	if hostname == "" {
+		return "", fmt.Errorf("Reverse DNS template rendered an empty hostname")
+	}
+	return hostname, nil
+}
+
+// setReverseDNS sets the PTR records of the public IPv4 address and of
+// all IPv6 addresses of the VPS.
+func (d *Driver) setReverseDNS() error {
+	if d.ReverseDNS == "" {
+		return nil
+	}
+
+	client := d.getClient()
+	hostname, err := d.renderReverseDNS(d.IPAddress)
+	if err != nil {
+		return err
+	}
+	log.Infof("Setting reverse DNS of %s to %s", d.IPAddress, hostname)
+	if err := client.SetIPv4ReverseDNS(d.MachineID, d.IPAddress, hostname); err != nil {
+		return err
+	}
+
+	if !d.IPv6 {
+		return nil
+	}
+
+	machine, err := client.GetServer(d.MachineID)
+	if err != nil {
+		return err
+	}
+	for _, network := range machine.V6Networks {
+		if network.MainIP == "" {
+			continue
+		}
+		hostname, err := d.renderReverseDNS(network.MainIP)
+		if err != nil {
+			return err
+		}
+		log.Infof("Setting reverse DNS of %s to %s", network.MainIP, hostname)
+		if err := client.SetIPv6ReverseDNS(d.MachineID, network.MainIP, hostname); err != nil {
+			return err
+		}
+		d.ReverseDNSIPv6 = append(d.ReverseDNSIPv6, network.MainIP)
+	}
+
+	return nil
+}
+
+// resetReverseDNS restores the default PTR records of the VPS.
+func (d *Driver) resetReverseDNS() error {
+	if d.ReverseDNS == "" || d.MachineID == "" {
+		return nil
+	}
+
+	client := d.getClient()
+	if isValidIP(d.IPAddress) {
+		log.Infof("Restoring default reverse DNS of %s", d.IPAddress)
+		if err := client.DefaultIPv4ReverseDNS(d.MachineID, d.IPAddress); err != nil && !vultr.IsNotFound(err) {
+			return err
+		}
+	}
+
+	for len(d.ReverseDNSIPv6) > 0 {
+		ip := d.ReverseDNSIPv6[0]
+		log.Infof("Deleting reverse DNS of %s", ip)
+		if err := client.DeleteIPv6ReverseDNS(d.MachineID, ip); err != nil && !vultr.IsNotFound(err) {
+			return err
+		}
+		d.ReverseDNSIPv6 = d.ReverseDNSIPv6[1:]
+	}
+
+	return nil
+}
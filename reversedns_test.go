@@ -0,0 +1,103 @@
+package vultr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderReverseDNS(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	driver.ReverseDNS = "{{.MachineName}}.example.com"
+	hostname, err := driver.renderReverseDNS("1.2.3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "default.example.com", hostname)
+
+	driver.ReverseDNS = "{{.MachineName}}-r{{.RegionID}}.example.com"
+	hostname, err = driver.renderReverseDNS("1.2.3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "default-r1.example.com", hostname)
+
+	driver.ReverseDNS = "{{.Unknown}}.example.com"
+	_, err = driver.renderReverseDNS("1.2.3.4")
+	assert.Error(t, err)
+
+	driver.ReverseDNS = "{{.MachineName"
+	_, err = driver.renderReverseDNS("1.2.3.4")
+	assert.Error(t, err)
+}
+
+// newReverseDNSTestDriver returns a driver whose API records the reverse
+// DNS calls as "<path> <ip> <entry>".
+func newReverseDNSTestDriver(calls *[]string) (*Driver, *httptest.Server) {
+	driver, server := newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/server/list" {
+			fmt.Fprint(w, `{"SUBID":"576965","main_ip":"1.2.3.4","v6_networks":[`+
+				`{"v6_network":"2001:db8::","v6_main_ip":"2001:db8::1","v6_network_size":"64"},`+
+				`{"v6_network":"2001:db9::","v6_main_ip":"2001:db9::1","v6_network_size":"64"}]}`)
+			return
+		}
+		r.ParseForm()
+		*calls = append(*calls, fmt.Sprintf("%s %s %s", r.URL.Path, r.Form.Get("ip"), r.Form.Get("entry")))
+		if r.Form.Get("ip") == "2001:db8::1" && r.URL.Path == "/v1/server/reverse_delete_ipv6" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	driver.MachineName = "web-1"
+	driver.MachineID = "576965"
+	driver.IPAddress = "1.2.3.4"
+	driver.ReverseDNS = "{{.MachineName}}.example.com"
+	return driver, server
+}
+
+func TestSetReverseDNS(t *testing.T) {
+	var calls []string
+	driver, server := newReverseDNSTestDriver(&calls)
+	defer server.Close()
+
+	assert.NoError(t, driver.setReverseDNS())
+	assert.Equal(t, []string{"/v1/server/reverse_set_ipv4 1.2.3.4 web-1.example.com"}, calls)
+	assert.Empty(t, driver.ReverseDNSIPv6)
+
+	calls = nil
+	driver.IPv6 = true
+	assert.NoError(t, driver.setReverseDNS())
+	assert.Equal(t, []string{
+		"/v1/server/reverse_set_ipv4 1.2.3.4 web-1.example.com",
+		"/v1/server/reverse_set_ipv6 2001:db8::1 web-1.example.com",
+		"/v1/server/reverse_set_ipv6 2001:db9::1 web-1.example.com",
+	}, calls)
+	assert.Equal(t, []string{"2001:db8::1", "2001:db9::1"}, driver.ReverseDNSIPv6)
+}
+
+func TestResetReverseDNS(t *testing.T) {
+	var calls []string
+	driver, server := newReverseDNSTestDriver(&calls)
+
+	// addresses whose reverse DNS is already gone (404) are skipped
+	driver.ReverseDNSIPv6 = []string{"2001:db8::1", "2001:db9::1"}
+	assert.NoError(t, driver.resetReverseDNS())
+	assert.Equal(t, []string{
+		"/v1/server/reverse_default_ipv4 1.2.3.4 ",
+		"/v1/server/reverse_delete_ipv6 2001:db8::1 ",
+		"/v1/server/reverse_delete_ipv6 2001:db9::1 ",
+	}, calls)
+	assert.Empty(t, driver.ReverseDNSIPv6)
+
+	// other errors keep the remaining addresses
+	server.Close()
+	driver.ReverseDNSIPv6 = []string{"2001:db9::1"}
+	assert.Error(t, driver.resetReverseDNS())
+	assert.Equal(t, []string{"2001:db9::1"}, driver.ReverseDNSIPv6)
+
+	// nothing to reset without a template
+	calls = nil
+	driver.ReverseDNS = ""
+	assert.NoError(t, driver.resetReverseDNS())
+	assert.Empty(t, calls)
+}
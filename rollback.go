@@ -0,0 +1,49 @@
+package vultr
+
+import (
+	"github.com/docker/machine/libmachine/log"
+)
+
+// undoStep reverts a single resource created during Create.
+type undoStep struct {
+	description string
+	undo        func() error
+}
+
+// transaction records the cloud resources created by Create so they can be
+// removed again if a later step fails.
+type transaction struct {
+	steps []undoStep
+}
+
+// record registers the undo function for a resource that has just been
+// created. description is used in log messages and should identify the
+// resource, e.g. "SSH key 5a1b2c".
+func (t *transaction) record(description string, undo func() error) {
+	t.steps = append(t.steps, undoStep{description: description, undo: undo})
+}
+
+// rollback undoes all recorded steps in reverse order. It keeps going when a
+// step fails and returns the descriptions of the resources that could not
+// be removed.
+func (t *transaction) rollback() []string {
+	var failed []string
+	for i := len(t.steps) - 1; i >= 0; i-- {
+		step := t.steps[i]
+		log.Infof("Rolling back %s", step.description)
+		if err := step.undo(); err != nil {
+			log.Errorf("Failed to roll back %s: %v", step.description, err)
+			failed = append(failed, step.description)
+		}
+	}
+	t.steps = nil
+
+	if len(failed) > 0 {
+		log.Warn("The following resources could not be cleaned up and must be removed manually from your Vultr account:")
+		for _, description := range failed {
+			log.Warnf(" - %s", description)
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,29 @@
+package vultr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionRollback(t *testing.T) {
+	var undone []string
+	tx := &transaction{}
+	for _, name := range []string{"key", "script", "server"} {
+		name := name
+		tx.record(name, func() error {
+			undone = append(undone, name)
+			if name == "script" {
+				return errors.New("boom")
+			}
+			return nil
+		})
+	}
+
+	failed := tx.rollback()
+
+	assert.Equal(t, []string{"server", "script", "key"}, undone)
+	assert.Equal(t, []string{"script"}, failed)
+	assert.Empty(t, tx.rollback())
+}
@@ -0,0 +1,235 @@
+package vultr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// scriptName returns the name of a startup script uploaded by the driver.
+// It contains the hash of the content, so identical scripts can be found
+// and reused.
+func scriptName(scriptType, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return fmt.Sprintf("docker-machine-%s-%s", scriptType, hex.EncodeToString(sum[:])[:16])
+}
+
+// findScript returns the ID of the startup script with the given name,
+// type and content, or 0 if there is none.
+func (d *Driver) findScript(name, scriptType, content string) (int, error) {
+	scripts, err := d.getClient().GetStartupScripts()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, script := range scripts {
+		if script.Name == name && script.Type == scriptType && script.Content == content {
+			return strconv.Atoi(script.ID)
+		}
+	}
+	return 0, nil
+}
+
+// validateBootScriptFile checks the file passed with --vultr-boot-script-file
+func (d *Driver) validateBootScriptFile() error {
+	if d.BootScriptFile == "" {
+		return nil
+	}
+
+	if d.BootScriptID != 0 {
+		return fmt.Errorf("--vultr-boot-script-file and --vultr-boot-script are mutually exclusive")
+	}
+	if d.OSID == 159 {
+		return fmt.Errorf("--vultr-boot-script-file can't be used with the 'Custom OS' (OS ID 159)")
+	}
+
+	info, err := os.Stat(d.BootScriptFile)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("Unable to find boot script file at %s", d.BootScriptFile)
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("Boot script file %s is empty", d.BootScriptFile)
+	}
+	return nil
+}
+
+// uploadBootScript uploads --vultr-boot-script-file as boot script.
+// Machines using the same boot script share it, see claimSharedScript.
+// Boot scripts uploaded by earlier versions of the driver, named without
+// the machines using them, are reused but never deleted.
+func (d *Driver) uploadBootScript(tx *transaction) error {
+	buf, err := ioutil.ReadFile(d.BootScriptFile)
+	if err != nil {
+		return err
+	}
+
+	content := string(buf)
+	name := scriptName("boot", content)
+	id, err := d.findScript(name, "boot", content)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		log.Infof("Using existing boot script %s (ID %d)", name, id)
+		d.BootScriptID = id
+		return nil
+	}
+
+	if d.BootScriptID, err = d.claimSharedScript("boot", content); err != nil {
+		return err
+	}
+	d.BootScriptShared = true
+	tx.record(fmt.Sprintf("boot script %d", d.BootScriptID), d.releaseBootScript)
+	return nil
+}
+
+// releaseBootScript releases the boot script uploaded by uploadBootScript,
+// see releaseSharedScript. Boot scripts that already existed are kept.
+func (d *Driver) releaseBootScript() error {
+	if !d.BootScriptShared || d.BootScriptID == 0 {
+		return nil
+	}
+	if err := d.releaseSharedScript(d.BootScriptID, "boot"); err != nil {
+		return err
+	}
+	d.BootScriptID = 0
+	d.BootScriptShared = false
+	return nil
+}
+
+// findSharedScript returns the shared startup script with the given type
+// and content, or nil if there is none.
+func (d *Driver) findSharedScript(scriptType, content string) (*vultr.StartupScript, error) {
+	scripts, err := d.getClient().GetStartupScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	base := scriptName(scriptType, content)
+	for i := range scripts {
+		if _, ok := sharedUsers(scripts[i].Name, base); ok && scripts[i].Type == scriptType && scripts[i].Content == content {
+			return &scripts[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// claimSharedScript returns the ID of the startup script with the given
+// type and content, after adding the machine to the users in its name. If
+// there is none, it is created. Scripts are named after their content, so
+// machines using the same script share it.
+func (d *Driver) claimSharedScript(scriptType, content string) (int, error) {
+	client := d.getClient()
+	base := scriptName(scriptType, content)
+	existing, err := d.findSharedScript(scriptType, content)
+	if err != nil {
+		return 0, err
+	}
+
+	if existing != nil {
+		existing.Name, _ = addSharedUser(existing.Name, base, d.MachineName)
+		if err := client.UpdateStartupScript(*existing); err != nil {
+			return 0, err
+		}
+		log.Infof("Using existing %s script %s (ID %s)", scriptType, base, existing.ID)
+		return strconv.Atoi(existing.ID)
+	}
+
+	script, err := client.CreateStartupScript(sharedName(base, []string{d.MachineName}), content, scriptType)
+	if err != nil {
+		return 0, err
+	}
+	log.Infof("Uploaded %s script %s (ID %s)", scriptType, base, script.ID)
+	return strconv.Atoi(script.ID)
+}
+
+// releaseSharedScript removes the machine from the users of a startup
+// script claimed by claimSharedScript and deletes the script once no
+// machine uses it. Scripts renamed by the user are kept.
+func (d *Driver) releaseSharedScript(id int, scriptType string) error {
+	client := d.getClient()
+	script, err := d.getScript(strconv.Itoa(id))
+	if err != nil {
+		return err
+	}
+	if script == nil {
+		log.Infof("%s script %d doesn't exist, assuming it is already deleted", scriptType, id)
+		return nil
+	}
+
+	base := scriptName(scriptType, script.Content)
+	others, ok := removeSharedUser(script.Name, base, d.MachineName)
+	if !ok {
+		log.Infof("Keeping %s script %s, it was renamed", scriptType, script.Name)
+		return nil
+	}
+	if len(others) > 0 {
+		log.Infof("%s script %s is still used by %s, not deleting it", scriptType, base, strings.Join(others, ", "))
+		script.Name = sharedName(base, others)
+		return client.UpdateStartupScript(*script)
+	}
+
+	log.Infof("Deleting %s script %s", scriptType, base)
+	if err := client.DeleteStartupScript(script.ID); err != nil && !vultr.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// createBootScript creates the iPXE script that boots the VPS, shared by
+// the machines booting the same script, see claimSharedScript.
+func (d *Driver) createBootScript(tx *transaction, content string) error {
+	log.Debugf("Using the following PXE script:")
+	log.Debugf("%s", content)
+
+	id, err := d.claimSharedScript("pxe", content)
+	if err != nil {
+		return err
+	}
+	d.PxeScriptID = id
+	d.PxeScriptShared = true
+	tx.record(fmt.Sprintf("PXE script %d", d.PxeScriptID), d.releasePXEScript)
+	return nil
+}
+
+// releasePXEScript releases the PXE script created by createBootScript,
+// see releaseSharedScript. PXE scripts created by earlier versions of the
+// driver belong to the machine alone and are deleted.
+func (d *Driver) releasePXEScript() error {
+	if d.PxeScriptID == 0 {
+		return nil
+	}
+	if !d.PxeScriptShared {
+		return d.deleteBootScript()
+	}
+	if err := d.releaseSharedScript(d.PxeScriptID, "pxe"); err != nil {
+		return err
+	}
+	d.PxeScriptID = 0
+	return nil
+}
+
+// getScript returns the startup script with the given ID, or nil if it
+// doesn't exist.
+func (d *Driver) getScript(id string) (*vultr.StartupScript, error) {
+	scripts, err := d.getClient().GetStartupScripts()
+	if err != nil {
+		return nil, err
+	}
+	for i := range scripts {
+		if scripts[i].ID == id {
+			return &scripts[i], nil
+		}
+	}
+	return nil, nil
+}
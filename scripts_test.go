@@ -0,0 +1,200 @@
+package vultr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBootScript = "#!/bin/sh\necho hello\n"
+
+func TestScriptName(t *testing.T) {
+	name := scriptName("boot", testBootScript)
+	assert.Regexp(t, "^docker-machine-boot-[0-9a-f]{16}$", name)
+	assert.Equal(t, name, scriptName("boot", testBootScript))
+	assert.NotEqual(t, name, scriptName("boot", testBootScript+"\n"))
+	assert.NotEqual(t, name, scriptName("pxe", testBootScript))
+}
+
+func TestUploadBootScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-scripts")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "boot.sh")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testBootScript), 0600))
+
+	var calls []string
+	responses := map[string]string{
+		"/v1/startupscript/list":    `{}`,
+		"/v1/startupscript/create":  `{"SCRIPTID":5}`,
+		"/v1/startupscript/update":  "",
+		"/v1/startupscript/destroy": "",
+	}
+	driver, server := newTestDriver(responses, &calls)
+	defer server.Close()
+
+	driver.MachineName = "web-1"
+	driver.OSID = 215
+	driver.BootScriptFile = path
+	assert.NoError(t, driver.validateBootScriptFile())
+
+	var tx transaction
+	assert.NoError(t, driver.uploadBootScript(&tx))
+	assert.Equal(t, 5, driver.BootScriptID)
+	assert.True(t, driver.BootScriptShared)
+	assert.Equal(t, []string{"/v1/startupscript/list", "/v1/startupscript/list", "/v1/startupscript/create"}, calls)
+
+	// web-2 uses the script as well, so it is kept
+	name := scriptName("boot", testBootScript)
+	list := `{"5":{"SCRIPTID":"5","name":%q,"type":"boot","script":%q}}`
+	responses["/v1/startupscript/list"] = fmt.Sprintf(list, name+": web-1, web-2", testBootScript)
+	calls = nil
+	assert.NoError(t, driver.releaseBootScript())
+	assert.Equal(t, []string{"/v1/startupscript/list", "/v1/startupscript/update"}, calls)
+	assert.Zero(t, driver.BootScriptID)
+	assert.False(t, driver.BootScriptShared)
+
+	// the last machine using it deletes it
+	responses["/v1/startupscript/list"] = fmt.Sprintf(list, name+": web-2", testBootScript)
+	driver.MachineName = "web-2"
+	calls = nil
+	assert.NoError(t, driver.uploadBootScript(&tx))
+	assert.Equal(t, 5, driver.BootScriptID)
+	assert.Equal(t, []string{"/v1/startupscript/list", "/v1/startupscript/list", "/v1/startupscript/update"}, calls)
+
+	calls = nil
+	assert.NoError(t, driver.releaseBootScript())
+	assert.Equal(t, []string{"/v1/startupscript/list", "/v1/startupscript/destroy"}, calls)
+}
+
+func TestUploadBootScriptReuse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-scripts")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "boot.sh")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testBootScript), 0600))
+
+	list := fmt.Sprintf(`{"3":{"SCRIPTID":"3","name":%q,"type":"boot","script":%q}}`, scriptName("boot", testBootScript), testBootScript)
+	var calls []string
+	driver, server := newTestDriver(map[string]string{
+		"/v1/startupscript/list": list,
+	}, &calls)
+	defer server.Close()
+
+	driver.BootScriptFile = path
+	var tx transaction
+	assert.NoError(t, driver.uploadBootScript(&tx))
+	assert.Equal(t, 3, driver.BootScriptID)
+	assert.False(t, driver.BootScriptShared)
+	assert.Equal(t, []string{"/v1/startupscript/list"}, calls)
+
+	calls = nil
+	assert.NoError(t, driver.releaseBootScript())
+	assert.Empty(t, calls)
+	assert.Equal(t, 3, driver.BootScriptID)
+}
+
+func TestValidateBootScriptFile(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.OSID = 215
+	driver.BootScriptFile = "/does/not/exist"
+	assert.Error(t, driver.validateBootScriptFile())
+
+	driver.BootScriptID = 3
+	assert.EqualError(t, driver.validateBootScriptFile(), "--vultr-boot-script-file and --vultr-boot-script are mutually exclusive")
+
+	driver.BootScriptID = 0
+	driver.OSID = 159
+	assert.Error(t, driver.validateBootScriptFile())
+}
+
+func TestCreateBootScriptShared(t *testing.T) {
+	const content = "#!ipxe\nboot\n"
+	name := scriptName("pxe", content)
+
+	var calls []string
+	driver, server := newTestDriver(map[string]string{
+		"/v1/startupscript/list":   `{}`,
+		"/v1/startupscript/create": `{"SCRIPTID":7}`,
+	}, &calls)
+	defer server.Close()
+
+	driver.MachineName = "web-1"
+	driver.VultrTag = "web"
+	var tx transaction
+	assert.NoError(t, driver.createBootScript(&tx, content))
+	assert.Equal(t, []string{"/v1/startupscript/list", "/v1/startupscript/create"}, calls)
+	assert.Equal(t, 7, driver.PxeScriptID)
+	assert.True(t, driver.PxeScriptShared)
+	assert.Len(t, tx.steps, 1)
+
+	// a machine booting the same script adds itself to the name, once
+	var updated []string
+	list := fmt.Sprintf(`{"7":{"SCRIPTID":"7","name":%q,"type":"pxe","script":%q}}`, name+": web-1, web-2", content)
+	shared, sharedServer := newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/startupscript/list":
+			fmt.Fprint(w, list)
+		case "/v1/startupscript/update":
+			r.ParseForm()
+			updated = append(updated, r.Form.Get("name"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer sharedServer.Close()
+
+	for _, machine := range []string{"web-2", "web-3"} {
+		other := NewDriver(machine, "path")
+		other.client = shared.client
+		tx = transaction{}
+		assert.NoError(t, other.createBootScript(&tx, content))
+		assert.Equal(t, 7, other.PxeScriptID)
+		assert.Len(t, tx.steps, 1)
+	}
+	assert.Equal(t, []string{name + ": web-1, web-2", name + ": web-1, web-2, web-3"}, updated)
+}
+
+func TestReleasePXEScript(t *testing.T) {
+	const content = "#!ipxe\nboot\n"
+	name := scriptName("pxe", content)
+	tests := []struct {
+		name  string
+		calls []string
+	}{
+		// still used by web-2
+		{name + ": web-1, web-2", []string{"/v1/startupscript/list", "/v1/startupscript/update"}},
+		// last user
+		{name + ": web-1", []string{"/v1/startupscript/list", "/v1/startupscript/destroy"}},
+		// renamed by the user
+		{"my script", []string{"/v1/startupscript/list"}},
+	}
+
+	for _, tt := range tests {
+		var calls []string
+		driver, server := newTestDriver(map[string]string{
+			"/v1/startupscript/list":    fmt.Sprintf(`{"7":{"SCRIPTID":"7","name":%q,"type":"pxe","script":%q}}`, tt.name, content),
+			"/v1/startupscript/update":  "",
+			"/v1/startupscript/destroy": "",
+		}, &calls)
+
+		driver.MachineName = "web-1"
+		driver.PxeScriptID = 7
+		driver.PxeScriptShared = true
+		assert.NoError(t, driver.releasePXEScript(), tt.name)
+		assert.Equal(t, tt.calls, calls, tt.name)
+		assert.Zero(t, driver.PxeScriptID)
+		server.Close()
+	}
+}
@@ -0,0 +1,66 @@
+package vultr
+
+import (
+	"strings"
+)
+
+// sharedUsersSeparator separates the name of a resource shared by several
+// machines from the machines using it, e.g.
+// "docker-machine-pxe-<hash>: web-1, web-2". The list is updated with a
+// read followed by a write, so machines sharing a resource must not be
+// created or removed concurrently.
+const sharedUsersSeparator = ": "
+
+// sharedName returns the name of a shared resource used by users
+func sharedName(base string, users []string) string {
+	return base + sharedUsersSeparator + strings.Join(users, ", ")
+}
+
+// sharedUsers returns the machines listed in the name of a shared
+// resource, and false if the name doesn't belong to base, i.e. the
+// resource wasn't created by the driver or was renamed.
+func sharedUsers(name, base string) ([]string, bool) {
+	if !strings.HasPrefix(name, base+sharedUsersSeparator) {
+		return nil, false
+	}
+
+	var users []string
+	for _, user := range strings.Split(strings.TrimPrefix(name, base+sharedUsersSeparator), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			users = append(users, user)
+		}
+	}
+	return users, true
+}
+
+// addSharedUser returns the name of a shared resource with user added to
+// the machines using it, unless it is listed already.
+func addSharedUser(name, base, user string) (string, bool) {
+	users, ok := sharedUsers(name, base)
+	if !ok {
+		return name, false
+	}
+	for _, u := range users {
+		if u == user {
+			return name, true
+		}
+	}
+	return sharedName(base, append(users, user)), true
+}
+
+// removeSharedUser returns the machines other than user listed in the
+// name of a shared resource. The resource can be deleted if there are
+// none.
+func removeSharedUser(name, base, user string) ([]string, bool) {
+	users, ok := sharedUsers(name, base)
+	if !ok {
+		return nil, false
+	}
+	var others []string
+	for _, u := range users {
+		if u != user {
+			others = append(others, u)
+		}
+	}
+	return others, true
+}
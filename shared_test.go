@@ -0,0 +1,49 @@
+package vultr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedUsers(t *testing.T) {
+	const base = "docker-machine-pxe-0123456789abcdef"
+
+	users, ok := sharedUsers(base+": web-1, web-2", base)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"web-1", "web-2"}, users)
+	assert.Equal(t, base+": web-1, web-2", sharedName(base, users))
+
+	_, ok = sharedUsers(base+"0: web-1", base)
+	assert.False(t, ok)
+	_, ok = sharedUsers(base, base)
+	assert.False(t, ok)
+	_, ok = sharedUsers("my script", base)
+	assert.False(t, ok)
+}
+
+func TestAddSharedUser(t *testing.T) {
+	name, ok := addSharedUser("docker-machine: web-1, web-2", "docker-machine", "web-2")
+	assert.True(t, ok)
+	assert.Equal(t, "docker-machine: web-1, web-2", name)
+
+	name, _ = addSharedUser(name, "docker-machine", "web-3")
+	assert.Equal(t, "docker-machine: web-1, web-2, web-3", name)
+
+	name, ok = addSharedUser("laptop", "docker-machine", "web-1")
+	assert.False(t, ok)
+	assert.Equal(t, "laptop", name)
+}
+
+func TestRemoveSharedUser(t *testing.T) {
+	others, ok := removeSharedUser("docker-machine: web-1, web-2", "docker-machine", "web-1")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"web-2"}, others)
+
+	others, ok = removeSharedUser("docker-machine: web-1", "docker-machine", "web-1")
+	assert.True(t, ok)
+	assert.Empty(t, others)
+
+	_, ok = removeSharedUser("laptop", "docker-machine", "web-1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,364 @@
+package vultr
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// sshKeyBase starts the name of SSH keys uploaded by the driver. The
+// rest of the name lists the machines using the key, see sharedName.
+const sshKeyBase = "docker-machine"
+
+// fingerprint returns the SHA256 fingerprint of a public key in
+// authorized_keys format, as shown by ssh-keygen -l.
+func fingerprint(publicKey string) (string, error) {
+	fields := strings.Fields(publicKey)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("Invalid SSH public key")
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("Invalid SSH public key: %v", err)
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// findSSHKey returns the SSH key in the account with the same fingerprint
+// as publicKey, or nil if there is none.
+func (d *Driver) findSSHKey(publicKey string) (*vultr.SSHKey, error) {
+	want, err := fingerprint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := d.getClient().GetSSHKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if fp, err := fingerprint(key.Key); err == nil && fp == want {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+// createSSHKey generates the machine's SSH key of the type selected by
+// --vultr-ssh-key-type and uploads its public key.
+func (d *Driver) createSSHKey(tx *transaction) error {
+	if err := generateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	id, reused, err := d.uploadSSHKey(tx, string(publicKey))
+	if err != nil {
+		return err
+	}
+	d.SSHKeyID = id
+	d.SSHKeyReused = reused
+	return nil
+}
+
+// uploadSSHKey uploads a public key, unless a key with the same
+// fingerprint is in the account already. Keys uploaded by the driver are
+// shared: the machine is added to the users in their name.
+func (d *Driver) uploadSSHKey(tx *transaction, publicKey string) (string, bool, error) {
+	client := d.getClient()
+	existing, err := d.findSSHKey(publicKey)
+	if err != nil {
+		return "", false, err
+	}
+
+	if existing != nil {
+		log.Infof("Using existing SSH key %s with the same fingerprint", existing.Name)
+		if name, ok := addSharedUser(existing.Name, sshKeyBase, d.MachineName); ok {
+			existing.Name = name
+			if err := client.UpdateSSHKey(*existing); err != nil {
+				return "", false, err
+			}
+			tx.record("SSH key "+existing.ID, func() error { return d.releaseKey(existing.ID, false) })
+		}
+		return existing.ID, true, nil
+	}
+
+	key, err := client.CreateSSHKey(sharedName(sshKeyBase, []string{d.MachineName}), publicKey)
+	if err != nil {
+		return "", false, err
+	}
+	tx.record("SSH key "+key.ID, func() error { return d.releaseKey(key.ID, false) })
+	return key.ID, false, nil
+}
+
+// releaseSSHKey releases the machine's SSH key, see releaseKey.
+func (d *Driver) releaseSSHKey() error {
+	if d.SSHKeyID == "" {
+		return nil
+	}
+	// keys not named by the driver were uploaded by a previous version
+	// of it, named after the machine, unless they were reused
+	if err := d.releaseKey(d.SSHKeyID, !d.SSHKeyReused); err != nil {
+		return err
+	}
+	d.SSHKeyID = ""
+	return nil
+}
+
+// releaseAuthorizedKeys releases the keys uploaded from
+// --vultr-authorized-key-file, see releaseKey.
+func (d *Driver) releaseAuthorizedKeys() error {
+	for len(d.UploadedSSHKeyIDs) > 0 {
+		if err := d.releaseKey(d.UploadedSSHKeyIDs[0], false); err != nil {
+			return err
+		}
+		d.UploadedSSHKeyIDs = d.UploadedSSHKeyIDs[1:]
+	}
+	return nil
+}
+
+// releaseKey removes the machine from the users of an SSH key uploaded by
+// the driver and deletes the key once no machine uses it. Other keys are
+// only deleted if deleteUnmanaged is set.
+func (d *Driver) releaseKey(id string, deleteUnmanaged bool) error {
+	client := d.getClient()
+	key, err := d.getSSHKey(id)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		log.Infof("SSH key %s doesn't exist, assuming it is already deleted", id)
+		return nil
+	}
+
+	others, managed := removeSharedUser(key.Name, sshKeyBase, d.MachineName)
+	if !managed {
+		if !deleteUnmanaged {
+			log.Infof("Keeping SSH key %s, it was not uploaded by docker-machine", key.Name)
+			return nil
+		}
+		return client.DeleteSSHKey(id)
+	}
+	if len(others) > 0 {
+		log.Infof("SSH key is still used by %s, not deleting it", strings.Join(others, ", "))
+		key.Name = sharedName(sshKeyBase, others)
+		return client.UpdateSSHKey(*key)
+	}
+
+	return client.DeleteSSHKey(id)
+}
+
+// getSSHKey returns the SSH key with the given ID, or nil if it doesn't
+// exist.
+func (d *Driver) getSSHKey(id string) (*vultr.SSHKey, error) {
+	keys, err := d.getClient().GetSSHKeys()
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if keys[i].ID == id {
+			return &keys[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// resolveAuthorizedKeys collects the public keys authorized on the VPS in
+// addition to the machine's key: those of the further --vultr-ssh-key-id
+// and the ones read from --vultr-authorized-key-file.
+func (d *Driver) resolveAuthorizedKeys() error {
+	d.AuthorizedKeys = nil
+	for _, id := range d.ExtraSSHKeyIDs {
+		key, err := d.getPublicKeyByID(id)
+		if err != nil {
+			return err
+		}
+		log.Infof("Authorizing SSH key: %s", key.Name)
+		d.AuthorizedKeys = append(d.AuthorizedKeys, strings.TrimSpace(key.Key))
+	}
+
+	for _, path := range d.AuthorizedKeyFiles {
+		keys, err := readAuthorizedKeyFile(path)
+		if err != nil {
+			return err
+		}
+		d.AuthorizedKeys = append(d.AuthorizedKeys, keys...)
+	}
+	return nil
+}
+
+// readAuthorizedKeyFile returns the public keys in a file in
+// authorized_keys format, one per line. Blank lines and comments are
+// skipped.
+func readAuthorizedKeyFile(path string) ([]string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read authorized key file: %v", err)
+	}
+
+	var keys []string
+	for i, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := fingerprint(line); err != nil {
+			return nil, fmt.Errorf("%v: %s line %d", err, path, i+1)
+		}
+		keys = append(keys, line)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("No SSH public key found in %s", path)
+	}
+	return keys, nil
+}
+
+// sshKeyIDs returns the comma separated IDs of all SSH keys authorized on
+// the VPS, starting with the machine's key.
+func (d *Driver) sshKeyIDs() string {
+	ids := append([]string{d.SSHKeyID}, d.ExtraSSHKeyIDs...)
+	return strings.Join(append(ids, d.UploadedSSHKeyIDs...), ",")
+}
+
+// uploadAuthorizedKeys uploads each key of --vultr-authorized-key-file, so
+// they can be passed to the create call. Keys that were in the account
+// already are reused and kept by releaseAuthorizedKeys.
+func (d *Driver) uploadAuthorizedKeys(tx *transaction) error {
+	uploaded := make(map[string]bool)
+	for _, path := range d.AuthorizedKeyFiles {
+		keys, err := readAuthorizedKeyFile(path)
+		if err != nil {
+			return err
+		}
+		for _, publicKey := range keys {
+			// the same key may be listed more than once
+			fp, _ := fingerprint(publicKey)
+			if uploaded[fp] {
+				continue
+			}
+			uploaded[fp] = true
+
+			id, _, err := d.uploadSSHKey(tx, publicKey)
+			if err != nil {
+				return err
+			}
+			d.UploadedSSHKeyIDs = append(d.UploadedSSHKeyIDs, id)
+		}
+	}
+	return nil
+}
+
+// privateKeyFingerprint returns the SHA256 fingerprint of the public key
+// belonging to a private key file. Keys in the OpenSSH format carry the
+// public key in their unencrypted header, which is read directly, since
+// the vendored SSH library doesn't support this format.
+func privateKeyFingerprint(data []byte) (string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", fmt.Errorf("No PEM encoded private key found")
+	}
+
+	var blob []byte
+	if block.Type == "OPENSSH PRIVATE KEY" {
+		var err error
+		if blob, err = openSSHPublicKeyBlob(block.Bytes); err != nil {
+			return "", err
+		}
+	} else {
+		signer, err := gossh.ParsePrivateKey(data)
+		if err != nil {
+			return "", err
+		}
+		blob = signer.PublicKey().Marshal()
+	}
+
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// openSSHPublicKeyBlob extracts the public key from the header of a key
+// in the "openssh-key-v1" format.
+func openSSHPublicKeyBlob(data []byte) ([]byte, error) {
+	const magic = "openssh-key-v1\x00"
+	if !strings.HasPrefix(string(data), magic) {
+		return nil, fmt.Errorf("Invalid OpenSSH private key")
+	}
+	rest := data[len(magic):]
+
+	readString := func() ([]byte, error) {
+		if len(rest) < 4 {
+			return nil, fmt.Errorf("Invalid OpenSSH private key")
+		}
+		n := binary.BigEndian.Uint32(rest)
+		if uint32(len(rest)-4) < n {
+			return nil, fmt.Errorf("Invalid OpenSSH private key")
+		}
+		s := rest[4 : 4+n]
+		rest = rest[4+n:]
+		return s, nil
+	}
+
+	// cipher, kdf and kdf options
+	for i := 0; i < 3; i++ {
+		if _, err := readString(); err != nil {
+			return nil, err
+		}
+	}
+	if len(rest) < 4 || binary.BigEndian.Uint32(rest) != 1 {
+		return nil, fmt.Errorf("OpenSSH private key files with multiple keys are not supported")
+	}
+	rest = rest[4:]
+	return readString()
+}
+
+// validateSSHPrivateKey checks that the file passed with
+// --vultr-ssh-private-key belongs to the public key of --vultr-ssh-key-id.
+func (d *Driver) validateSSHPrivateKey(publicKey string) error {
+	data, err := ioutil.ReadFile(d.SSHPrivateKey)
+	if err != nil {
+		return fmt.Errorf("Unable to read SSH private key: %v", err)
+	}
+
+	private, err := privateKeyFingerprint(data)
+	if err != nil {
+		return fmt.Errorf("Unable to read SSH private key %s: %v", d.SSHPrivateKey, err)
+	}
+	public, err := fingerprint(publicKey)
+	if err != nil {
+		return err
+	}
+
+	if private != public {
+		return fmt.Errorf("SSH private key %s (%s) doesn't match the SSH key %s (%s)", d.SSHPrivateKey, private, d.SSHKeyID, public)
+	}
+	return nil
+}
+
+// copySSHPrivateKey copies the file passed with --vultr-ssh-private-key
+// into the machine directory, like the generic driver does.
+func (d *Driver) copySSHPrivateKey() error {
+	d.SSHKeyPath = d.ResolveStorePath(filepath.Base(d.SSHPrivateKey))
+	if err := mcnutils.CopyFile(d.SSHPrivateKey, d.SSHKeyPath); err != nil {
+		return fmt.Errorf("Unable to copy SSH private key: %v", err)
+	}
+	if err := os.Chmod(d.SSHKeyPath, 0600); err != nil {
+		return fmt.Errorf("Unable to set permissions on the SSH private key: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package vultr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMMhRvpqT2sudJSWGfQHvyKXL6iv9jyP5mnvmPAjeTpb test@example.com"
+
+func TestFingerprint(t *testing.T) {
+	fp, err := fingerprint(testPublicKey + "\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "SHA256:FBtINywvi055973r56bQOE3kuq+oVGE7NqIG4fGwNlw", fp)
+
+	// the comment doesn't matter
+	other, _ := fingerprint("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMMhRvpqT2sudJSWGfQHvyKXL6iv9jyP5mnvmPAjeTpb")
+	assert.Equal(t, fp, other)
+
+	_, err = fingerprint("ssh-ed25519")
+	assert.Error(t, err)
+	_, err = fingerprint("ssh-ed25519 !!!")
+	assert.Error(t, err)
+}
+
+func TestFindSSHKey(t *testing.T) {
+	driver, server := newTestDriver(map[string]string{
+		"/v1/sshkey/list": `{"541b4960f23bd":{"SSHKEYID":"541b4960f23bd","name":"laptop","ssh_key":"` + testPublicKey + `","date_created":null}}`,
+	}, nil)
+	defer server.Close()
+
+	key, err := driver.findSSHKey("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMMhRvpqT2sudJSWGfQHvyKXL6iv9jyP5mnvmPAjeTpb other-comment")
+	assert.NoError(t, err)
+	if assert.NotNil(t, key) {
+		assert.Equal(t, "541b4960f23bd", key.ID)
+	}
+
+	key, err = driver.findSSHKey("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIP9WAmxAXE6+xoDLPhoplc6Ptj5uPj9VqbtN3XcJ5z13")
+	assert.NoError(t, err)
+	assert.Nil(t, key)
+}
+
+func TestReleaseSSHKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		reused bool
+		calls  []string
+	}{
+		{"docker-machine: web-1, web-2", true, []string{"/v1/sshkey/list", "/v1/sshkey/update"}},
+		{"docker-machine: web-1", false, []string{"/v1/sshkey/list", "/v1/sshkey/destroy"}},
+		{"laptop", true, []string{"/v1/sshkey/list"}},
+		{"web-1", false, []string{"/v1/sshkey/list", "/v1/sshkey/destroy"}},
+	}
+
+	for _, tt := range tests {
+		var calls []string
+		driver, server := newTestDriver(map[string]string{
+			"/v1/sshkey/list":    `{"abc":{"SSHKEYID":"abc","name":"` + tt.name + `","ssh_key":"` + testPublicKey + `"}}`,
+			"/v1/sshkey/update":  "",
+			"/v1/sshkey/destroy": "",
+		}, &calls)
+
+		driver.MachineName = "web-1"
+		driver.SSHKeyID = "abc"
+		driver.SSHKeyReused = tt.reused
+		assert.NoError(t, driver.releaseSSHKey(), tt.name)
+		assert.Equal(t, tt.calls, calls, tt.name)
+		server.Close()
+	}
+}
+
+func TestUploadSSHKeyShared(t *testing.T) {
+	var updated []string
+	shared, server := newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sshkey/list":
+			fmt.Fprintf(w, `{"abc":{"SSHKEYID":"abc","name":"docker-machine: web-1, web-2","ssh_key":%q}}`, testPublicKey)
+		case "/v1/sshkey/update":
+			r.ParseForm()
+			updated = append(updated, r.Form.Get("name"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	// a machine listed already isn't added twice
+	for _, machine := range []string{"web-2", "web-3"} {
+		driver := NewDriver(machine, "path")
+		driver.client = shared.client
+		var tx transaction
+		id, reused, err := driver.uploadSSHKey(&tx, testPublicKey)
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", id)
+		assert.True(t, reused)
+		assert.Len(t, tx.steps, 1)
+	}
+	assert.Equal(t, []string{"docker-machine: web-1, web-2", "docker-machine: web-1, web-2, web-3"}, updated)
+}
+
+func TestPrivateKeyFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-sshkey")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	for _, keyType := range []string{sshKeyTypeRSA, sshKeyTypeECDSA, sshKeyTypeED25519} {
+		path := filepath.Join(dir, sshKeyFile(keyType))
+		assert.NoError(t, generateSSHKey(path, keyType))
+
+		private, _ := ioutil.ReadFile(path)
+		public, _ := ioutil.ReadFile(path + ".pub")
+		expected, err := fingerprint(string(public))
+		assert.NoError(t, err)
+
+		actual, err := privateKeyFingerprint(private)
+		assert.NoError(t, err, keyType)
+		assert.Equal(t, expected, actual, keyType)
+	}
+
+	_, err = privateKeyFingerprint([]byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestValidateSSHPrivateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-sshkey")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "deploy_key")
+	assert.NoError(t, generateSSHKey(source, sshKeyTypeED25519))
+	public, _ := ioutil.ReadFile(source + ".pub")
+
+	store := filepath.Join(dir, "store")
+	assert.NoError(t, os.MkdirAll(filepath.Join(store, "machines", "default"), 0700))
+	driver := NewDriver("default", store)
+	driver.SSHKeyID = "541b4960f23bd"
+	driver.SSHPrivateKey = source
+
+	assert.NoError(t, driver.validateSSHPrivateKey(string(public)))
+	assert.Error(t, driver.validateSSHPrivateKey(testPublicKey))
+
+	assert.NoError(t, driver.copySSHPrivateKey())
+	assert.Equal(t, driver.ResolveStorePath("deploy_key"), driver.GetSSHKeyPath())
+	info, err := os.Stat(driver.GetSSHKeyPath())
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
+
+func TestSSHKeyIDFlag(t *testing.T) {
+	driver := NewDriver("default", "path")
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"vultr-api-key":             "APIKEY",
+			"vultr-ssh-key-id":          []string{"541b4960f23bd", "5420e4a1bbd1c"},
+			"vultr-authorized-key-file": []string{"oncall.pub"},
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	assert.NoError(t, driver.SetConfigFromFlags(checkFlags))
+	assert.Equal(t, "541b4960f23bd", driver.SSHKeyID)
+	assert.Equal(t, []string{"5420e4a1bbd1c"}, driver.ExtraSSHKeyIDs)
+	assert.Equal(t, []string{"oncall.pub"}, driver.AuthorizedKeyFiles)
+
+	driver.UploadedSSHKeyIDs = []string{"5a1b2c3d4e5f6"}
+	assert.Equal(t, "541b4960f23bd,5420e4a1bbd1c,5a1b2c3d4e5f6", driver.sshKeyIDs())
+}
+
+func TestAuthorizedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-sshkey")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "oncall.pub")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testPublicKey+"\n"), 0600))
+
+	var calls []string
+	driver, server := newTestDriver(map[string]string{
+		"/v1/sshkey/list":   `{"5420e4a1bbd1c":{"SSHKEYID":"5420e4a1bbd1c","name":"laptop","ssh_key":"ssh-rsa AAAA laptop\n"}}`,
+		"/v1/sshkey/create": `{"SSHKEYID":"5a1b2c3d4e5f6"}`,
+	}, &calls)
+	defer server.Close()
+
+	driver.MachineName = "web-1"
+	driver.ExtraSSHKeyIDs = []string{"5420e4a1bbd1c"}
+	driver.AuthorizedKeyFiles = []string{path}
+
+	assert.NoError(t, driver.resolveAuthorizedKeys())
+	assert.Equal(t, []string{"ssh-rsa AAAA laptop", testPublicKey}, driver.AuthorizedKeys)
+
+	driver.VultrPublicKey = "ssh-rsa BBBB default\n"
+	config, err := driver.getCloudConfig()
+	assert.NoError(t, err)
+	assert.Contains(t, config, "ssh_authorized_keys:\n  - ssh-rsa BBBB default\n  - ssh-rsa AAAA laptop\n  - "+testPublicKey+"\n")
+
+	assert.NoError(t, driver.uploadAuthorizedKeys(&transaction{}))
+	assert.Equal(t, []string{"5a1b2c3d4e5f6"}, driver.UploadedSSHKeyIDs)
+	assert.Equal(t, "/v1/sshkey/create", calls[len(calls)-1])
+
+	driver.AuthorizedKeyFiles = []string{filepath.Join(dir, "missing.pub")}
+	assert.Error(t, driver.resolveAuthorizedKeys())
+}
+
+func TestAuthorizedKeyFileWithSeveralKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-sshkey")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	const otherKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIP9WAmxAXE6+xoDLPhoplc6Ptj5uPj9VqbtN3XcJ5z13 oncall@example.com"
+	path := filepath.Join(dir, "team.pub")
+	content := "# team keys\n" + testPublicKey + "\n\n" + otherKey + "\n" + testPublicKey + "\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	var created []string
+	driver, server := newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sshkey/list":
+			fmt.Fprint(w, `{}`)
+		case "/v1/sshkey/create":
+			r.ParseForm()
+			created = append(created, r.Form.Get("ssh_key"))
+			fmt.Fprintf(w, `{"SSHKEYID":"key%d"}`, len(created))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	driver.MachineName = "web-1"
+	driver.AuthorizedKeyFiles = []string{path}
+
+	assert.NoError(t, driver.resolveAuthorizedKeys())
+	assert.Equal(t, []string{testPublicKey, otherKey, testPublicKey}, driver.AuthorizedKeys)
+
+	var tx transaction
+	assert.NoError(t, driver.uploadAuthorizedKeys(&tx))
+	assert.Equal(t, []string{testPublicKey, otherKey}, created)
+	assert.Equal(t, []string{"key1", "key2"}, driver.UploadedSSHKeyIDs)
+	assert.Len(t, tx.steps, 2)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testPublicKey+"\nnot a key\n"), 0600))
+	if err := driver.resolveAuthorizedKeys(); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), path+" line 2")
+	}
+	assert.NoError(t, ioutil.WriteFile(path, []byte("# no keys\n"), 0600))
+	assert.EqualError(t, driver.resolveAuthorizedKeys(), "No SSH public key found in "+path)
+}
@@ -0,0 +1,158 @@
+package vultr
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	sshKeyTypeRSA     = "rsa"
+	sshKeyTypeED25519 = "ed25519"
+	sshKeyTypeECDSA   = "ecdsa"
+	defaultSSHKeyType = sshKeyTypeRSA
+)
+
+// sshKeyFiles maps the values of --vultr-ssh-key-type to the name of the
+// private key file in the machine store, as written by ssh-keygen.
+var sshKeyFiles = map[string]string{
+	sshKeyTypeRSA:     "id_rsa",
+	sshKeyTypeED25519: "id_ed25519",
+	sshKeyTypeECDSA:   "id_ecdsa",
+}
+
+// validateSSHKeyType checks the value of --vultr-ssh-key-type
+func validateSSHKeyType(keyType string) error {
+	if _, ok := sshKeyFiles[keyType]; !ok {
+		return fmt.Errorf("Invalid SSH key type '%s'. Must be one of: %s, %s, %s", keyType, sshKeyTypeRSA, sshKeyTypeED25519, sshKeyTypeECDSA)
+	}
+	return nil
+}
+
+// sshKeyFile returns the name of the private key file for the key type.
+// Machines created before --vultr-ssh-key-type existed use RSA keys.
+func sshKeyFile(keyType string) string {
+	if file, ok := sshKeyFiles[keyType]; ok {
+		return file
+	}
+	return sshKeyFiles[defaultSSHKeyType]
+}
+
+// generateSSHKey writes a new key pair of the given type to path and
+// path.pub, unless the private key exists already. RSA and ECDSA keys are
+// written in the PEM formats understood by both OpenSSH and the native
+// SSH client of docker-machine. Ed25519 keys use the OpenSSH format, the
+// only one OpenSSH reads them from.
+func generateSSHKey(path, keyType string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("Desired directory for SSH keys does not exist: %s", err)
+	}
+
+	var private *pem.Block
+	var public []byte
+	switch keyType {
+	case sshKeyTypeRSA:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return err
+		}
+		private = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		pub, err := gossh.NewPublicKey(&key.PublicKey)
+		if err != nil {
+			return err
+		}
+		public = gossh.MarshalAuthorizedKey(pub)
+	case sshKeyTypeECDSA:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		private = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+		pub, err := gossh.NewPublicKey(&key.PublicKey)
+		if err != nil {
+			return err
+		}
+		public = gossh.MarshalAuthorizedKey(pub)
+	case sshKeyTypeED25519:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		private, err = marshalED25519PrivateKey(key)
+		if err != nil {
+			return err
+		}
+		public = []byte("ssh-ed25519 " + base64.StdEncoding.EncodeToString(ed25519PublicKeyBlob(pub)) + "\n")
+	default:
+		return validateSSHKeyType(keyType)
+	}
+
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(private), 0600); err != nil {
+		return fmt.Errorf("Error writing SSH private key: %v", err)
+	}
+	if err := ioutil.WriteFile(path+".pub", public, 0600); err != nil {
+		return fmt.Errorf("Error writing SSH public key: %v", err)
+	}
+	return nil
+}
+
+// appendSSHString appends s in the SSH wire format: length, then bytes
+func appendSSHString(buf []byte, s []byte) []byte {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(s)))
+	return append(append(buf, length[:]...), s...)
+}
+
+func ed25519PublicKeyBlob(pub ed25519.PublicKey) []byte {
+	blob := appendSSHString(nil, []byte("ssh-ed25519"))
+	return appendSSHString(blob, pub)
+}
+
+// marshalED25519PrivateKey encodes key in the unencrypted
+// "openssh-key-v1" format described in OpenSSH's PROTOCOL.key.
+func marshalED25519PrivateKey(key ed25519.PrivateKey) (*pem.Block, error) {
+	check, err := rand.Int(rand.Reader, big.NewInt(1<<32))
+	if err != nil {
+		return nil, err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+
+	var checkBytes [4]byte
+	binary.BigEndian.PutUint32(checkBytes[:], uint32(check.Uint64()))
+	section := append(checkBytes[:], checkBytes[:]...)
+	section = appendSSHString(section, []byte("ssh-ed25519"))
+	section = appendSSHString(section, pub)
+	section = appendSSHString(section, key)
+	section = appendSSHString(section, nil) // comment
+	for i := byte(1); len(section)%8 != 0; i++ {
+		section = append(section, i)
+	}
+
+	buf := append([]byte("openssh-key-v1"), 0)
+	buf = appendSSHString(buf, []byte("none")) // cipher
+	buf = appendSSHString(buf, []byte("none")) // kdf
+	buf = appendSSHString(buf, nil)            // kdf options
+	buf = append(buf, 0, 0, 0, 1)              // number of keys
+	buf = appendSSHString(buf, ed25519PublicKeyBlob(pub))
+	buf = appendSSHString(buf, section)
+
+	return &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: buf}, nil
+}
@@ -0,0 +1,76 @@
+package vultr
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestGenerateSSHKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-sshkey")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		keyType, pemType, prefix string
+	}{
+		{sshKeyTypeRSA, "RSA PRIVATE KEY", "ssh-rsa "},
+		{sshKeyTypeECDSA, "EC PRIVATE KEY", "ecdsa-sha2-nistp256 "},
+		{sshKeyTypeED25519, "OPENSSH PRIVATE KEY", "ssh-ed25519 "},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, sshKeyFile(tt.keyType))
+		if !assert.NoError(t, generateSSHKey(path, tt.keyType), tt.keyType) {
+			continue
+		}
+
+		private, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		block, _ := pem.Decode(private)
+		if assert.NotNil(t, block, tt.keyType) {
+			assert.Equal(t, tt.pemType, block.Type)
+		}
+
+		public, err := ioutil.ReadFile(path + ".pub")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(public), tt.prefix), tt.keyType)
+		_, err = fingerprint(string(public))
+		assert.NoError(t, err)
+
+		if tt.keyType != sshKeyTypeED25519 {
+			signer, err := gossh.ParsePrivateKey(private)
+			if assert.NoError(t, err, tt.keyType) {
+				assert.Equal(t, string(public), string(gossh.MarshalAuthorizedKey(signer.PublicKey())))
+			}
+		} else {
+			assert.True(t, strings.HasPrefix(string(block.Bytes), "openssh-key-v1\x00"))
+		}
+
+		// existing keys are kept
+		assert.NoError(t, generateSSHKey(path, tt.keyType))
+		again, _ := ioutil.ReadFile(path)
+		assert.Equal(t, private, again)
+	}
+
+	assert.Error(t, generateSSHKey(filepath.Join(dir, "id_dsa"), "dsa"))
+}
+
+func TestSSHKeyPath(t *testing.T) {
+	for keyType, file := range map[string]string{"": "id_rsa", "rsa": "id_rsa", "ed25519": "id_ed25519", "ecdsa": "id_ecdsa"} {
+		driver := NewDriver("default", "path")
+		driver.SSHKeyType = keyType
+		assert.Equal(t, driver.ResolveStorePath(file), driver.GetSSHKeyPath())
+	}
+
+	assert.NoError(t, validateSSHKeyType("ed25519"))
+	assert.Error(t, validateSSHKeyType("dsa"))
+}
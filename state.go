@@ -0,0 +1,63 @@
+package vultr
+
+import (
+	"fmt"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// machineState maps the status, server state and power status reported by
+// the Vultr API to a docker-machine state. A VPS that has been closed
+// (destroyed) is reported as mcnerror.ErrHostDoesNotExist.
+//
+//	status     server state                      power status  state
+//	pending    *                                 *             Starting
+//	active     ok                                running       Running
+//	active     ok                                stopped       Stopped
+//	active     none, locked, installingbooting,  running       Starting
+//	           isomounting
+//	active     none, locked, installingbooting,  stopped       Stopped
+//	           isomounting
+//	suspended  *                                 *             Error
+//	closed     *                                 *             does not exist
+func (d *Driver) machineState(machine vultr.Server) (state.State, error) {
+	switch machine.Status {
+	case "":
+		if machine.ID == "" {
+			return state.Error, mcnerror.ErrHostDoesNotExist{Name: d.MachineName}
+		}
+	case "pending":
+		return state.Starting, nil
+	case "active":
+		return activeMachineState(machine)
+	case "suspended":
+		return state.Error, fmt.Errorf("Vultr VPS %s has been suspended. Please check your Vultr account", machine.ID)
+	case "closed":
+		return state.Error, mcnerror.ErrHostDoesNotExist{Name: d.MachineName}
+	}
+
+	return state.Error, fmt.Errorf("Unknown status of Vultr VPS %s (%s)", machine.ID, describeServer(machine))
+}
+
+func activeMachineState(machine vultr.Server) (state.State, error) {
+	switch machine.ServerState {
+	case "ok":
+		switch machine.PowerStatus {
+		case "running":
+			return state.Running, nil
+		case "stopped":
+			return state.Stopped, nil
+		}
+	case "none", "locked", "installingbooting", "isomounting":
+		switch machine.PowerStatus {
+		case "running":
+			return state.Starting, nil
+		case "stopped":
+			return state.Stopped, nil
+		}
+	}
+
+	return state.Error, fmt.Errorf("Unknown status of Vultr VPS %s (%s)", machine.ID, describeServer(machine))
+}
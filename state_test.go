@@ -0,0 +1,64 @@
+package vultr
+
+import (
+	"testing"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMachineState(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	tests := []struct {
+		status      string
+		serverState string
+		powerStatus string
+		state       state.State
+		err         string
+	}{
+		{"pending", "none", "stopped", state.Starting, ""},
+		{"pending", "installingbooting", "running", state.Starting, ""},
+		{"active", "ok", "running", state.Running, ""},
+		{"active", "ok", "stopped", state.Stopped, ""},
+		{"active", "none", "running", state.Starting, ""},
+		{"active", "none", "stopped", state.Stopped, ""},
+		{"active", "locked", "running", state.Starting, ""},
+		{"active", "locked", "stopped", state.Stopped, ""},
+		{"active", "installingbooting", "running", state.Starting, ""},
+		{"active", "installingbooting", "stopped", state.Stopped, ""},
+		{"active", "isomounting", "running", state.Starting, ""},
+		{"active", "isomounting", "stopped", state.Stopped, ""},
+		{"active", "ok", "unknown", state.Error, "Unknown status"},
+		{"active", "unknown", "running", state.Error, "Unknown status"},
+		{"suspended", "ok", "stopped", state.Error, "suspended"},
+		{"suspended", "locked", "running", state.Error, "suspended"},
+		{"closed", "none", "stopped", state.Error, "Host does not exist"},
+		{"unknown", "ok", "running", state.Error, "Unknown status"},
+	}
+
+	for _, tt := range tests {
+		machine := vultr.Server{ID: "123", Status: tt.status, ServerState: tt.serverState, PowerStatus: tt.powerStatus}
+		st, err := driver.machineState(machine)
+		assert.Equal(t, tt.state, st, describeServer(machine))
+		if tt.err == "" {
+			assert.NoError(t, err, describeServer(machine))
+		} else if assert.Error(t, err, describeServer(machine)) {
+			assert.Contains(t, err.Error(), tt.err, describeServer(machine))
+		}
+	}
+}
+
+func TestMachineStateNotFound(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	st, err := driver.machineState(vultr.Server{})
+	assert.Equal(t, state.Error, st)
+	assert.Equal(t, mcnerror.ErrHostDoesNotExist{Name: "default"}, err)
+
+	st, err = driver.GetState()
+	assert.Equal(t, state.Error, st)
+	assert.Equal(t, mcnerror.ErrHostDoesNotExist{Name: "default"}, err)
+}
@@ -0,0 +1,304 @@
+package vultr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// maxUserDataSize is the maximum size of the user data accepted by Vultr
+const maxUserDataSize = 64 * 1024
+
+// jinjaHeader marks user data that cloud-init renders itself as a Jinja
+// template. Such files are not rendered by the driver.
+const jinjaHeader = "## template: jinja"
+
+// userDataTypes maps the header of a user data part to its MIME type.
+// Longer headers sharing a prefix with shorter ones must come first.
+var userDataTypes = []struct {
+	header      string
+	contentType string
+}{
+	{"#cloud-config-archive", "text/cloud-config-archive"},
+	{cloudConfigHeader, "text/cloud-config"},
+	{"#cloud-boothook", "text/cloud-boothook"},
+	{"#include-once", "text/x-include-once-url"},
+	{"#include", "text/x-include-url"},
+	{"#upstart-job", "text/upstart-job"},
+	{"#part-handler", "text/part-handler"},
+	{jinjaHeader, "text/jinja2"},
+	{"#!", "text/x-shellscript"},
+}
+
+// userDataPart is a single file of a multi-part user data archive
+type userDataPart struct {
+	Filename    string
+	ContentType string
+	Content     string
+}
+
+// userDataType detects the MIME type of a user data part from its first line
+func userDataType(content string) (string, error) {
+	for _, t := range userDataTypes {
+		if strings.HasPrefix(content, t.header) {
+			return t.contentType, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown user data type. When combining multiple files, each must start with one of '#cloud-config', '#!', '#cloud-boothook', '#include', '#upstart-job', '#part-handler' or '%s'", jinjaHeader)
+}
+
+// multipartUserData combines the parts into a MIME multi-part archive
+// as understood by cloud-init.
+func multipartUserData(parts []userDataPart) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range parts {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", part.ContentType+`; charset="utf-8"`)
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Transfer-Encoding", "7bit")
+		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, part.Filename))
+
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.WriteString(w, part.Content); err != nil {
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	header := fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\nMIME-Version: 1.0\n\n", writer.Boundary())
+	return header + body.String(), nil
+}
+
+// summarizeUserData describes the user data parts without their content,
+// which may contain secrets.
+func summarizeUserData(parts []userDataPart) string {
+	var summary []string
+	for _, part := range parts {
+		summary = append(summary, fmt.Sprintf("%s (%s, %d bytes)", part.Filename, part.ContentType, len(part.Content)))
+	}
+	return strings.Join(summary, ", ")
+}
+
+// userDataVars holds the variables available to user data templates
+type userDataVars struct {
+	MachineName       string
+	RegionID          int
+	PlanID            int
+	OSID              int
+	Tag               string
+	SSHPublicKey      string
+	IPv6              bool
+	PrivateNetworking bool
+	// Env holds the environment variables selected by --vultr-userdata-env
+	Env map[string]string
+	// Vars holds the values given by --vultr-userdata-var
+	Vars map[string]string
+}
+
+// parseUserDataVars parses the key=value pairs of --vultr-userdata-var
+func parseUserDataVars(values []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid user data variable '%s'. Expected key=value", value)
+		}
+		vars[parts[0]] = parts[1]
+	}
+	return vars, nil
+}
+
+func parseUserDataTemplate(name, content string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing user data template %s: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// validateUserData checks that the user data files exist, have a known
+// type and are valid templates.
+func (d *Driver) validateUserData() error {
+	for _, path := range d.UserDataFiles {
+		buf, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Unable to find user data file at %s", path)
+		}
+		if err != nil {
+			return err
+		}
+
+		content := string(buf)
+		if d.OSID == 159 {
+			profile, err := d.profile()
+			if err != nil {
+				return err
+			}
+			if err := profile.ValidateUserData(path, content); err != nil {
+				return err
+			}
+		}
+		if _, err := userDataType(content); err != nil && len(d.UserDataFiles) > 1 && d.OSID != 159 {
+			return fmt.Errorf("%v: %s", err, path)
+		}
+		if strings.HasPrefix(content, jinjaHeader) {
+			continue
+		}
+		if _, err := parseUserDataTemplate(path, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// templateVars returns the machine variables for user data templates
+func (d *Driver) templateVars() (*userDataVars, error) {
+	publicKey, err := d.publicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string)
+	for _, name := range d.UserDataEnv {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("Environment variable %s selected by --vultr-userdata-env is not set", name)
+		}
+		env[name] = value
+	}
+
+	vars := d.UserDataVars
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+
+	return &userDataVars{
+		MachineName:       d.MachineName,
+		RegionID:          d.RegionID,
+		PlanID:            d.PlanID,
+		OSID:              d.OSID,
+		Tag:               d.VultrTag,
+		SSHPublicKey:      strings.TrimSpace(publicKey),
+		IPv6:              d.IPv6,
+		PrivateNetworking: d.PrivateNetworking,
+		Env:               env,
+		Vars:              vars,
+	}, nil
+}
+
+// renderUserData renders the user data template with the machine variables
+func (d *Driver) renderUserData(name, content string) (string, error) {
+	if strings.HasPrefix(content, jinjaHeader) {
+		return content, nil
+	}
+
+	tmpl, err := parseUserDataTemplate(name, content)
+	if err != nil {
+		return "", err
+	}
+
+	vars, err := d.templateVars()
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, vars); err != nil {
+		return "", fmt.Errorf("Error rendering user data template %s: %v", name, err)
+	}
+	return buffer.String(), nil
+}
+
+// readUserData reads and renders the user data files
+func (d *Driver) readUserData() ([]userDataPart, error) {
+	var parts []userDataPart
+	for _, path := range d.UserDataFiles {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := d.renderUserData(path, string(buf))
+		if err != nil {
+			return nil, err
+		}
+
+		// a single file is passed as is, so its type doesn't matter
+		contentType, err := userDataType(content)
+		if err != nil {
+			if len(d.UserDataFiles) > 1 && d.OSID != 159 {
+				return nil, fmt.Errorf("%v: %s", err, path)
+			}
+			contentType = "text/plain"
+		}
+
+		parts = append(parts, userDataPart{
+			Filename:    filepath.Base(path),
+			ContentType: contentType,
+			Content:     content,
+		})
+	}
+
+	return parts, nil
+}
+
+// buildUserData assembles the user data sent to Vultr. For the 'Custom OS'
+// it is generated by the provisioning profile from the user's files.
+// Otherwise a single file is passed as is and multiple files are combined
+// into a MIME multi-part archive.
+func (d *Driver) buildUserData() (string, error) {
+	parts, err := d.readUserData()
+	if err != nil {
+		return "", err
+	}
+
+	var userdata string
+	if d.OSID == 159 {
+		profile, err := d.profile()
+		if err != nil {
+			return "", err
+		}
+		userdata, err = profile.UserData(d, parts)
+		if err != nil {
+			return "", err
+		}
+		parts = []userDataPart{{Filename: "generated", ContentType: "OS profile", Content: userdata}}
+	} else {
+		switch len(parts) {
+		case 0:
+		case 1:
+			userdata = parts[0].Content
+		default:
+			userdata, err = multipartUserData(parts)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if len(userdata) > maxUserDataSize {
+		return "", fmt.Errorf("User data is %d bytes, exceeding the limit of %d bytes", len(userdata), maxUserDataSize)
+	}
+
+	if userdata != "" {
+		log.Debugf("Using %d bytes of user data: %s", len(userdata), summarizeUserData(parts))
+	}
+
+	return userdata, nil
+}
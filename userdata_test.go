@@ -0,0 +1,176 @@
+package vultr
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserDataVars(t *testing.T) {
+	vars, err := parseUserDataVars([]string{"role=worker", "labels=a=b,c=d", "empty="})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"role": "worker", "labels": "a=b,c=d", "empty": ""}, vars)
+
+	_, err = parseUserDataVars([]string{"role"})
+	assert.Error(t, err)
+
+	_, err = parseUserDataVars([]string{"=worker"})
+	assert.Error(t, err)
+}
+
+func TestRenderUserData(t *testing.T) {
+	os.Setenv("VULTR_TEST_TOKEN", "s3cr3t")
+	defer os.Unsetenv("VULTR_TEST_TOKEN")
+
+	driver := NewDriver("web-1", "path")
+	driver.VultrPublicKey = "ssh-rsa AAAA default\n"
+	driver.VultrTag = "web"
+	driver.PrivateNetworking = true
+	driver.UserDataVars = map[string]string{"role": "frontend"}
+	driver.UserDataEnv = []string{"VULTR_TEST_TOKEN"}
+
+	const tpl = `#cloud-config
+hostname: {{.MachineName}}
+runcmd:
+  - echo "{{.Vars.role}} {{.Tag}} {{.RegionID}}/{{.PlanID}}/{{.OSID}}" > /etc/role
+  - echo "{{.Env.VULTR_TEST_TOKEN}}" > /etc/token{{if .PrivateNetworking}}
+  - echo private{{end}}
+ssh_authorized_keys:
+  - {{.SSHPublicKey}}
+`
+	userdata, err := driver.renderUserData("test", tpl)
+	assert.NoError(t, err)
+	assert.Equal(t, `#cloud-config
+hostname: web-1
+runcmd:
+  - echo "frontend web 1/201/159" > /etc/role
+  - echo "s3cr3t" > /etc/token
+  - echo private
+ssh_authorized_keys:
+  - ssh-rsa AAAA default
+`, userdata)
+
+	_, err = driver.renderUserData("test", "{{.Vars.unknown}}")
+	assert.Error(t, err)
+
+	driver.UserDataEnv = []string{"VULTR_TEST_UNSET"}
+	_, err = driver.renderUserData("test", "{{.MachineName}}")
+	assert.Error(t, err)
+
+	jinja := "## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n"
+	userdata, err = driver.renderUserData("test", jinja)
+	assert.NoError(t, err)
+	assert.Equal(t, jinja, userdata)
+}
+
+func TestUserDataType(t *testing.T) {
+	tests := []struct {
+		content     string
+		contentType string
+	}{
+		{"#cloud-config\nhostname: x\n", "text/cloud-config"},
+		{"#cloud-config-archive\n- type: text/cloud-config\n", "text/cloud-config-archive"},
+		{"#!/bin/sh\necho hi\n", "text/x-shellscript"},
+		{"#cloud-boothook\n#!/bin/sh\n", "text/cloud-boothook"},
+		{"#include\nhttp://example.com/a\n", "text/x-include-url"},
+		{"#include-once\nhttp://example.com/a\n", "text/x-include-once-url"},
+		{"#upstart-job\n", "text/upstart-job"},
+		{"#part-handler\n", "text/part-handler"},
+		{"## template: jinja\n#cloud-config\n", "text/jinja2"},
+	}
+
+	for _, tt := range tests {
+		contentType, err := userDataType(tt.content)
+		assert.NoError(t, err, tt.content)
+		assert.Equal(t, tt.contentType, contentType, tt.content)
+	}
+
+	_, err := userDataType("hostname: x\n")
+	assert.Error(t, err)
+}
+
+func TestBuildUserDataMultipart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-userdata")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config.yml": "#cloud-config\nhostname: {{.MachineName}}\n",
+		"setup.sh":   "#!/bin/sh\necho {{.Vars.role}}\n",
+	}
+	driver := NewDriver("web-1", dir)
+	driver.OSID = 215
+	driver.VultrPublicKey = "ssh-rsa AAAA default"
+	driver.UserDataVars = map[string]string{"role": "frontend"}
+	for _, name := range []string{"config.yml", "setup.sh"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(files[name]), 0600))
+		driver.UserDataFiles = append(driver.UserDataFiles, path)
+	}
+
+	assert.NoError(t, driver.validateUserData())
+	userdata, err := driver.buildUserData()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(userdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	expected := []struct{ filename, contentType, content string }{
+		{"config.yml", "text/cloud-config", "#cloud-config\nhostname: web-1\n"},
+		{"setup.sh", "text/x-shellscript", "#!/bin/sh\necho frontend\n"},
+	}
+	for _, e := range expected {
+		part, err := reader.NextPart()
+		if !assert.NoError(t, err) {
+			return
+		}
+		content, _ := ioutil.ReadAll(part)
+		assert.Equal(t, e.filename, part.FileName())
+		assert.True(t, strings.HasPrefix(part.Header.Get("Content-Type"), e.contentType))
+		assert.Equal(t, e.content, string(content))
+	}
+
+	large := filepath.Join(dir, "large.sh")
+	assert.NoError(t, ioutil.WriteFile(large, []byte("#!/bin/sh\n"+strings.Repeat("#", maxUserDataSize)), 0600))
+	driver.UserDataFiles = append(driver.UserDataFiles, large)
+	_, err = driver.buildUserData()
+	assert.Error(t, err)
+}
+
+func TestValidateUserDataUnknownType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vultr-userdata")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "plain.txt")
+	script := filepath.Join(dir, "script.sh")
+	assert.NoError(t, ioutil.WriteFile(plain, []byte("just some text\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\n"), 0600))
+
+	driver := NewDriver("web-1", dir)
+	driver.OSID = 215
+	driver.UserDataFiles = []string{plain}
+	assert.NoError(t, driver.validateUserData())
+
+	driver.UserDataFiles = []string{plain, script}
+	assert.Error(t, driver.validateUserData())
+}
@@ -1,47 +1,87 @@
 package vultr
 
 import (
-	"bytes"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"strconv"
-	"strings"
-	"text/template"
-	"time"
 
 	vultr "github.com/JamesClonk/vultr/lib"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnflag"
-	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 )
 
 type Driver struct {
 	*drivers.BaseDriver
-	APIKey            string
-	APIEndpoint       string
-	MachineID         string
-	PrivateIP         string
-	OSID              int
-	RegionID          int
-	PlanID            int
-	SSHKeyID          string
-	VultrPublicKey    string
-	ROSVersion        string
-	ReservedIP        string
-	IPv6              bool
-	Backups           bool
-	PrivateNetworking bool
-	PxeScriptID       int
-	BootScriptID      int
-	CustomPxeScript   bool
-	UserDataFile      string
-	SnapshotID        string
-	VultrTag          string
-	FirewallGroupID   string
-	client            *vultr.Client
+	APIKey             string
+	APIEndpoint        string
+	MachineID          string
+	PrivateIP          string
+	IPv6Address        string
+	OSID               int
+	RegionID           int
+	PlanID             int
+	MinVCpus           int
+	MinRAMMB           int
+	MinDiskGB          int
+	MaxMonthlyPrice    float64
+	PlanType           string
+	SSHKeyID           string
+	VultrPublicKey     string
+	SSHKeyReused       bool
+	SSHKeyType         string
+	SSHPrivateKey      string
+	ExtraSSHKeyIDs     []string
+	AuthorizedKeyFiles []string
+	AuthorizedKeys     []string
+	UploadedSSHKeyIDs  []string
+	ROSVersion         string
+	OSProfile          string
+	FlatcarChannel     string
+	FlatcarVersion     string
+	ReservedIP         string
+	ReservedIPID       string
+	ReservedIPCreated  bool
+	ReservedIPRetain   bool
+	CreateReservedIP   bool
+	IPv6               bool
+	Backups            bool
+	PrivateNetworking  bool
+	PxeScriptID        int
+	PxeScriptShared    bool
+	BootScriptID       int
+	BootScriptFile     string
+	BootScriptShared   bool
+	CustomPxeScript    bool
+	PxeTemplate        string
+	IPXEChainURL       string
+	UserDataFiles      []string
+	UserDataVars       map[string]string
+	UserDataEnv        []string
+	SnapshotID         string
+	VultrTag           string
+	FirewallGroupID    string
+	CreateTimeout      int
+	BlockStorage       []BlockStorageVolume
+	BlockStorageSize   int
+	BlockStorageKeep   bool
+	ManagedFirewall    bool
+	FirewallSources    []string
+	DNSDomain          string
+	DNSTTL             int
+	DNSRecordIDs       []int
+	DNSUseFQDN         bool
+	ReverseDNS         string
+	ReverseDNSIPv6     []string
+	AddressMode        string
+	client             *vultr.Client
+	regionName         string
+	regionIDs          []int
+	regionFallbacks    []regionChoice
+	planName           string
+	osName             string
 }
 
 const (
@@ -51,6 +91,8 @@ const (
 	defaultSSHuser     = "root"
 	defaultROSVersion  = "v1.0.2"
 	clientMaxRetries   = 4
+	defaultTimeout     = 600
+	defaultDNSTTL      = 300
 	defaultAPIEndpoint = ""
 )
 
@@ -93,31 +135,125 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Vultr operating system ID. Default: RancherOS.",
 			Value:  defaultOS,
 		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_REGION_NAME",
+			Name:   "vultr-region",
+			Usage:  "Vultr region code or name (e.g. 'ams'), a comma separated list of regions in order of preference, or a filter like 'continent:Europe' or 'country:DE'. Overrides --vultr-region-id.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_PLAN_NAME",
+			Name:   "vultr-plan",
+			Usage:  "Vultr plan name (e.g. 'vc2-1c-1gb'). Overrides --vultr-plan-id.",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "VULTR_MIN_VCPUS",
+			Name:   "vultr-min-vcpus",
+			Usage:  "Minimum number of vCPUs of the plan. Selects the cheapest matching plan instead of --vultr-plan-id.",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "VULTR_MIN_RAM_MB",
+			Name:   "vultr-min-ram-mb",
+			Usage:  "Minimum RAM of the plan in MB. Selects the cheapest matching plan instead of --vultr-plan-id.",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "VULTR_MIN_DISK_GB",
+			Name:   "vultr-min-disk-gb",
+			Usage:  "Minimum disk size of the plan in GB. Selects the cheapest matching plan instead of --vultr-plan-id.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_MAX_MONTHLY_PRICE",
+			Name:   "vultr-max-monthly-price",
+			Usage:  "Maximum monthly price of the plan in USD (e.g. '10.00'). Selects the cheapest matching plan instead of --vultr-plan-id.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_PLAN_TYPE",
+			Name:   "vultr-plan-type",
+			Usage:  "Type of the plan (e.g. 'SSD', 'HIGHFREQUENCY', 'DEDICATED'). Selects the cheapest matching plan instead of --vultr-plan-id.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_OS_NAME",
+			Name:   "vultr-os",
+			Usage:  "Vultr operating system name (e.g. 'Ubuntu 16.04 x64'). Overrides --vultr-os-id.",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "VULTR_ROS_VERSION",
 			Name:   "vultr-ros-version",
 			Usage:  "RancherOS version to use (eg. v0.6.0). Default: v1.0.2",
 			Value:  defaultROSVersion,
 		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_OS_PROFILE",
+			Name:   "vultr-os-profile",
+			Usage:  "Operating system to provision on the 'Custom OS' (rancheros or flatcar). Default: rancheros",
+			Value:  defaultOSProfile,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_FLATCAR_CHANNEL",
+			Name:   "vultr-flatcar-channel",
+			Usage:  "Flatcar Container Linux release channel (stable, beta, alpha or lts). Default: stable",
+			Value:  defaultFlatcarChannel,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_FLATCAR_VERSION",
+			Name:   "vultr-flatcar-version",
+			Usage:  "Flatcar Container Linux version to use (eg. 3815.2.0). Default: current",
+			Value:  defaultFlatcarVersion,
+		},
 		mcnflag.IntFlag{
 			EnvVar: "VULTR_PXE_SCRIPT",
 			Name:   "vultr-pxe-script",
 			Usage:  "ID of a PXE script in your Vultr account.",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_PXE_TEMPLATE",
+			Name:   "vultr-pxe-template",
+			Usage:  "Path to an iPXE script template uploaded as PXE script, or URL of an iPXE script to chain-load.",
+		},
 		mcnflag.IntFlag{
 			EnvVar: "VULTR_BOOT_SCRIPT",
 			Name:   "vultr-boot-script",
 			Usage:  "ID of a boot script in your Vultr account. Mutually exclusive of --vultr-pxe-script.",
 		},
 		mcnflag.StringFlag{
+			EnvVar: "VULTR_BOOT_SCRIPT_FILE",
+			Name:   "vultr-boot-script-file",
+			Usage:  "Path to a boot script uploaded to your Vultr account. Mutually exclusive of --vultr-boot-script.",
+		},
+		mcnflag.StringSliceFlag{
 			EnvVar: "VULTR_SSH_KEY",
 			Name:   "vultr-ssh-key-id",
-			Usage:  "ID of an existing SSH key in your Vultr account.",
+			Usage:  "ID of an existing SSH key in your Vultr account. Can be specified multiple times to authorize further keys.",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "VULTR_AUTHORIZED_KEY_FILE",
+			Name:   "vultr-authorized-key-file",
+			Usage:  "Path to a file of public SSH keys to authorize on the VPS, one per line. Can be specified multiple times. Machines sharing a key must not be created or removed concurrently.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_SSH_PRIVATE_KEY",
+			Name:   "vultr-ssh-private-key",
+			Usage:  "Path to the private key of the SSH key given by --vultr-ssh-key-id.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_SSH_KEY_TYPE",
+			Name:   "vultr-ssh-key-type",
+			Usage:  "Type of the generated SSH key (rsa, ed25519 or ecdsa). Ed25519 keys can't be used with --native-ssh. Default: rsa",
+			Value:  defaultSSHKeyType,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "VULTR_RESERVED_IP",
 			Name:   "vultr-reserved-ip",
-			Usage:  "ID of a reserved IP in your Vultr account.",
+			Usage:  "ID or address of a reserved IP in your Vultr account.",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "VULTR_CREATE_RESERVED_IP",
+			Name:   "vultr-create-reserved-ip",
+			Usage:  "Reserve a new IPv4 address and use it as the main IP of the VPS.",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "VULTR_RESERVED_IP_RETAIN",
+			Name:   "vultr-reserved-ip-retain",
+			Usage:  "Keep the IP reserved by --vultr-create-reserved-ip when the machine is removed.",
 		},
 		mcnflag.BoolFlag{
 			EnvVar: "VULTR_IPV6",
@@ -134,10 +270,20 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "vultr-backups",
 			Usage:  "Enable automatic backups for the VPS.",
 		},
-		mcnflag.StringFlag{
+		mcnflag.StringSliceFlag{
 			EnvVar: "VULTR_USERDATA",
 			Name:   "vultr-userdata",
-			Usage:  "Path to a file containing cloud-init user data.",
+			Usage:  "Path to a file containing cloud-init user data. The file is rendered as a Go template. Multiple files are combined into a MIME multi-part archive.",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "VULTR_USERDATA_VAR",
+			Name:   "vultr-userdata-var",
+			Usage:  "Variable (key=value) available as {{.Vars.key}} in the user data template.",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "VULTR_USERDATA_ENV",
+			Name:   "vultr-userdata-env",
+			Usage:  "Name of an environment variable available as {{.Env.NAME}} in the user data template.",
 		},
 		mcnflag.StringFlag{
 			EnvVar: "VULTR_SNAPSHOT",
@@ -154,14 +300,75 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "vultr-firewall-group",
 			Usage:  "ID of existing firewall group to assign.",
 		},
+		mcnflag.BoolFlag{
+			EnvVar: "VULTR_MANAGED_FIREWALL",
+			Name:   "vultr-managed-firewall",
+			Usage:  "Create a firewall group for the VPS that only allows SSH and Docker traffic. Mutually exclusive of --vultr-firewall-group.",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "VULTR_FIREWALL_SOURCE",
+			Name:   "vultr-firewall-source",
+			Usage:  "Source network (CIDR) allowed by the managed firewall group. Default: any.",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "VULTR_BLOCK_STORAGE_SIZE",
+			Name:   "vultr-block-storage-size",
+			Usage:  "Size in GB of a new block storage volume to attach to the VPS.",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "VULTR_BLOCK_STORAGE_KEEP",
+			Name:   "vultr-block-storage-keep",
+			Usage:  "Keep the volume created by --vultr-block-storage-size when the machine is removed.",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "VULTR_BLOCK_STORAGE_ID",
+			Name:   "vultr-block-storage-id",
+			Usage:  "ID of an existing block storage volume to attach, optionally followed by ':keep' (default) or ':delete'.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_DNS_DOMAIN",
+			Name:   "vultr-dns-domain",
+			Usage:  "Vultr DNS domain in which to publish address records for the machine.",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "VULTR_DNS_TTL",
+			Name:   "vultr-dns-ttl",
+			Usage:  "TTL of the DNS records in seconds. Default: 300",
+			Value:  defaultDNSTTL,
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "VULTR_DNS_USE_FQDN",
+			Name:   "vultr-dns-use-fqdn",
+			Usage:  "Use the machine's FQDN instead of its IP address for the Docker URL.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_REVERSE_DNS",
+			Name:   "vultr-reverse-dns",
+			Usage:  "Template of the reverse DNS hostname of the VPS IP addresses, e.g. '{{.MachineName}}.example.com'.",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "VULTR_ADDRESS_MODE",
+			Name:   "vultr-address-mode",
+			Usage:  "Address used to connect to the VPS: 'public-v4', 'public-v6' or 'private'. Default: public-v4",
+			Value:  addressPublicV4,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "VULTR_CREATE_TIMEOUT",
+			Name:   "vultr-create-timeout",
+			Usage:  "Maximum number of seconds to wait for the VPS to become ready. Default: 600",
+			Value:  defaultTimeout,
+		},
 	}
 }
 
 func NewDriver(hostName, storePath string) *Driver {
 	d := &Driver{
-		OSID:     defaultOS,
-		PlanID:   defaultPlan,
-		RegionID: defaultRegion,
+		OSID:          defaultOS,
+		PlanID:        defaultPlan,
+		RegionID:      defaultRegion,
+		CreateTimeout: defaultTimeout,
+		AddressMode:   addressPublicV4,
+		DNSTTL:        defaultDNSTTL,
 		BaseDriver: &drivers.BaseDriver{
 			MachineName: hostName,
 			StorePath:   storePath,
@@ -170,8 +377,10 @@ func NewDriver(hostName, storePath string) *Driver {
 	return d
 }
 
+// GetSSHHostname always returns an IP address, so that provisioning
+// doesn't depend on the propagation of the machine's DNS records.
 func (d *Driver) GetSSHHostname() (string, error) {
-	return d.GetIP()
+	return d.address()
 }
 
 // DriverName returns the name of the driver
@@ -184,19 +393,54 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.APIEndpoint = flags.String("vultr-api-endpoint")
 	d.OSID = flags.Int("vultr-os-id")
 	d.ROSVersion = flags.String("vultr-ros-version")
+	d.OSProfile = flags.String("vultr-os-profile")
+	d.FlatcarChannel = flags.String("vultr-flatcar-channel")
+	d.FlatcarVersion = flags.String("vultr-flatcar-version")
 	d.RegionID = flags.Int("vultr-region-id")
 	d.PlanID = flags.Int("vultr-plan-id")
+	d.regionName = flags.String("vultr-region")
+	d.planName = flags.String("vultr-plan")
+	d.MinVCpus = flags.Int("vultr-min-vcpus")
+	d.MinRAMMB = flags.Int("vultr-min-ram-mb")
+	d.MinDiskGB = flags.Int("vultr-min-disk-gb")
+	d.PlanType = flags.String("vultr-plan-type")
+	d.osName = flags.String("vultr-os")
 	d.PxeScriptID = flags.Int("vultr-pxe-script")
+	if pxeTemplate := flags.String("vultr-pxe-template"); isURL(pxeTemplate) {
+		d.IPXEChainURL = pxeTemplate
+	} else {
+		d.PxeTemplate = pxeTemplate
+	}
 	d.BootScriptID = flags.Int("vultr-boot-script")
-	d.SSHKeyID = flags.String("vultr-ssh-key-id")
+	d.BootScriptFile = flags.String("vultr-boot-script-file")
+	if ids := flags.StringSlice("vultr-ssh-key-id"); len(ids) > 0 {
+		d.SSHKeyID = ids[0]
+		d.ExtraSSHKeyIDs = ids[1:]
+	}
+	d.AuthorizedKeyFiles = flags.StringSlice("vultr-authorized-key-file")
+	d.SSHKeyType = flags.String("vultr-ssh-key-type")
+	d.SSHPrivateKey = flags.String("vultr-ssh-private-key")
 	d.ReservedIP = flags.String("vultr-reserved-ip")
+	d.CreateReservedIP = flags.Bool("vultr-create-reserved-ip")
+	d.ReservedIPRetain = flags.Bool("vultr-reserved-ip-retain")
 	d.IPv6 = flags.Bool("vultr-ipv6")
 	d.PrivateNetworking = flags.Bool("vultr-private-networking")
 	d.Backups = flags.Bool("vultr-backups")
-	d.UserDataFile = flags.String("vultr-userdata")
+	d.UserDataFiles = flags.StringSlice("vultr-userdata")
+	d.UserDataEnv = flags.StringSlice("vultr-userdata-env")
 	d.SnapshotID = flags.String("vultr-snapshot-id")
 	d.VultrTag = flags.String("vultr-tag")
 	d.FirewallGroupID = flags.String("vultr-firewall-group")
+	d.ManagedFirewall = flags.Bool("vultr-managed-firewall")
+	d.FirewallSources = flags.StringSlice("vultr-firewall-source")
+	d.CreateTimeout = flags.Int("vultr-create-timeout")
+	d.BlockStorageSize = flags.Int("vultr-block-storage-size")
+	d.DNSDomain = flags.String("vultr-dns-domain")
+	d.DNSTTL = flags.Int("vultr-dns-ttl")
+	d.DNSUseFQDN = flags.Bool("vultr-dns-use-fqdn")
+	d.ReverseDNS = flags.String("vultr-reverse-dns")
+	d.AddressMode = flags.String("vultr-address-mode")
+	d.BlockStorageKeep = flags.Bool("vultr-block-storage-keep")
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
@@ -206,17 +450,64 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	if d.APIKey == "" {
 		return fmt.Errorf("Vultr driver requires the --vultr-api-key option")
 	}
+
+	if d.CreateTimeout <= 0 {
+		return fmt.Errorf("--vultr-create-timeout must be a positive number of seconds")
+	}
+
+	if err := validateSSHKeyType(d.SSHKeyType); err != nil {
+		return err
+	}
+
+	if price := flags.String("vultr-max-monthly-price"); price != "" {
+		maxPrice, err := strconv.ParseFloat(price, 64)
+		if err != nil || maxPrice <= 0 {
+			return fmt.Errorf("Invalid --vultr-max-monthly-price '%s'. Must be a positive number", price)
+		}
+		d.MaxMonthlyPrice = maxPrice
+	}
+
+	if d.MinVCpus < 0 || d.MinRAMMB < 0 || d.MinDiskGB < 0 {
+		return fmt.Errorf("--vultr-min-vcpus, --vultr-min-ram-mb and --vultr-min-disk-gb must not be negative")
+	}
+
+	if d.planName != "" && d.hasPlanRequirements() {
+		return fmt.Errorf("--vultr-plan can't be combined with --vultr-min-vcpus, --vultr-min-ram-mb, --vultr-min-disk-gb, --vultr-max-monthly-price or --vultr-plan-type")
+	}
+
+	vars, err := parseUserDataVars(flags.StringSlice("vultr-userdata-var"))
+	if err != nil {
+		return err
+	}
+	d.UserDataVars = vars
+
+	volumes, err := parseBlockStorageIDs(flags.StringSlice("vultr-block-storage-id"))
+	if err != nil {
+		return err
+	}
+	d.BlockStorage = volumes
 	return nil
 }
 
 func (d *Driver) PreCreateCheck() error {
-	if d.UserDataFile != "" {
-		if d.OSID == 159 {
-			return fmt.Errorf("--vultr-userdata does currently not support 'Custom OS' (OS ID 159)")
+	if err := d.resolveNames(); err != nil {
+		return err
+	}
+
+	if d.OSID == 159 {
+		profile, err := d.profile()
+		if err != nil {
+			return err
 		}
-		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
-			return fmt.Errorf("Unable to find user data file at %s", d.UserDataFile)
+		if err := profile.Validate(d); err != nil {
+			return err
 		}
+	} else if d.OSProfile != "" && d.OSProfile != defaultOSProfile {
+		return fmt.Errorf("--vultr-os-profile requires the 'Custom OS' (OS ID 159)")
+	}
+
+	if err := d.validateUserData(); err != nil {
+		return err
 	}
 
 	log.Info("Validating Vultr VPS parameters...")
@@ -233,6 +524,47 @@ func (d *Driver) PreCreateCheck() error {
 		return fmt.Errorf("--vultr-boot-script can't be used with the 'Custom OS' (OS ID 159)")
 	}
 
+	if err := d.validatePXETemplate(); err != nil {
+		return err
+	}
+
+	if err := d.validateBootScriptFile(); err != nil {
+		return err
+	}
+
+	if d.ManagedFirewall {
+		if d.FirewallGroupID != "" {
+			return fmt.Errorf("--vultr-managed-firewall and --vultr-firewall-group are mutually exclusive")
+		}
+		if _, err := parseFirewallSources(d.FirewallSources, d.IPv6); err != nil {
+			return err
+		}
+	} else if len(d.FirewallSources) > 0 {
+		return fmt.Errorf("--vultr-firewall-source requires --vultr-managed-firewall")
+	}
+
+	if err := d.validateAddressMode(); err != nil {
+		return err
+	}
+
+	if d.CreateReservedIP && d.ReservedIP != "" {
+		return fmt.Errorf("--vultr-create-reserved-ip and --vultr-reserved-ip are mutually exclusive")
+	}
+
+	if d.ReservedIPRetain && !d.CreateReservedIP {
+		return fmt.Errorf("--vultr-reserved-ip-retain requires --vultr-create-reserved-ip")
+	}
+
+	if d.DNSUseFQDN && d.DNSDomain == "" {
+		return fmt.Errorf("--vultr-dns-use-fqdn requires --vultr-dns-domain")
+	}
+
+	if d.ReverseDNS != "" {
+		if _, err := d.renderReverseDNS("192.0.2.1"); err != nil {
+			return err
+		}
+	}
+
 	if d.SnapshotID != "" && d.OSID == defaultOS {
 		//	reassign OSID to Snapshot OSID 164, if OSID is the defaultOS.
 		//	And allow user to specify an OSID, in case there is an API update in the future.
@@ -245,15 +577,39 @@ func (d *Driver) PreCreateCheck() error {
 			return err
 		}
 
-		log.Info("Using existing SSH public key: %s", key.Name)
+		log.Infof("Using existing SSH public key: %s", key.Name)
 		d.VultrPublicKey = key.Key
+
+		if d.SSHPrivateKey != "" {
+			if err := d.validateSSHPrivateKey(key.Key); err != nil {
+				return err
+			}
+		}
+	} else if d.SSHPrivateKey != "" {
+		return fmt.Errorf("--vultr-ssh-private-key requires --vultr-ssh-key-id")
+	}
+
+	if err := d.resolveAuthorizedKeys(); err != nil {
+		return err
 	}
 
 	if err := d.validateRegion(); err != nil {
 		return err
 	}
 
-	if err := d.validatePlan(); err != nil {
+	if err := d.chooseRegion(); err != nil {
+		return err
+	}
+
+	if err := d.validateBlockStorage(); err != nil {
+		return err
+	}
+
+	if err := d.validateDNSDomain(); err != nil {
+		return err
+	}
+
+	if err := d.resolveReservedIP(); err != nil {
 		return err
 	}
 
@@ -264,48 +620,68 @@ func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
-func (d *Driver) Create() error {
+func (d *Driver) Create() (err error) {
+	tx := &transaction{}
+	defer func() {
+		if err != nil {
+			log.Errorf("Error creating Vultr VPS: %v", err)
+			tx.rollback()
+		}
+	}()
+
+	if d.SSHPrivateKey != "" {
+		if err := d.copySSHPrivateKey(); err != nil {
+			return err
+		}
+	}
+
 	if d.SSHKeyID == "" {
 		log.Debug("Generating SSH key...")
-		key, err := d.createSSHKey()
-		if err != nil {
+		if err := d.createSSHKey(tx); err != nil {
 			return err
 		}
-		d.SSHKeyID = key.ID
+	}
+
+	if err := d.uploadAuthorizedKeys(tx); err != nil {
+		return err
 	}
 
 	log.Info("Creating Vultr VPS")
-	var userdata string
-	var err error
 	if d.OSID == 159 {
 		log.Info("Using PXE boot")
-		if d.PxeScriptID != 0 {
+		profile, err := d.profile()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.PxeScriptID != 0:
 			d.CustomPxeScript = true
-		} else {
-			log.Infof("Provisioning RancherOS (%s). SSH user set to 'rancher'.", d.ROSVersion)
-			d.SSHUser = "rancher"
-			if err := d.createBootScript(); err != nil {
+		case d.PxeTemplate != "":
+			if err := d.createPXETemplateScript(tx); err != nil {
+				return err
+			}
+		case d.IPXEChainURL != "":
+			log.Infof("Chain-loading iPXE script %s", d.IPXEChainURL)
+		default:
+			log.Infof("Provisioning %s. SSH user set to '%s'.", profile.Description(d), profile.SSHUser())
+			d.SSHUser = profile.SSHUser()
+			if err := d.createBootScript(tx, profile.PXEScript(d)); err != nil {
 				return err
 			}
 
-			log.Debugf("Created RancherOS PXE script: ID %d", d.PxeScriptID)
+			log.Debugf("Created PXE script: ID %d", d.PxeScriptID)
 		}
+	}
 
-		userdata, err = d.getCloudConfig()
-		if err != nil {
-			return err
-		}
-	} else if d.UserDataFile != "" {
-		buf, err := ioutil.ReadFile(d.UserDataFile)
-		if err != nil {
+	if d.BootScriptFile != "" {
+		if err := d.uploadBootScript(tx); err != nil {
 			return err
 		}
-		userdata = string(buf)
 	}
 
-	if userdata != "" {
-		log.Debugf("Using the following cloud-init user data:")
-		log.Debugf("%s", userdata)
+	userdata, err := d.buildUserData()
+	if err != nil {
+		return err
 	}
 
 	var scriptID int
@@ -316,54 +692,78 @@ func (d *Driver) Create() error {
 		scriptID = d.BootScriptID
 	}
 
-	client := d.getClient()
-	machine, err := client.CreateServer(
-		d.MachineName,
-		d.RegionID,
-		d.PlanID,
-		d.OSID,
-		&vultr.ServerOptions{
-			SSHKey:               d.SSHKeyID,
-			IPV6:                 d.IPv6,
-			PrivateNetworking:    d.PrivateNetworking,
-			AutoBackups:          d.Backups,
-			Script:               scriptID,
-			UserData:             userdata,
-			Snapshot:             d.SnapshotID,
-			Hostname:             d.MachineName,
-			DontNotifyOnActivate: true,
-			Tag:                  d.VultrTag,
-			FirewallGroupID:      d.FirewallGroupID,
-			ReservedIP:           d.ReservedIP,
-		})
-	if err != nil {
+	if err := d.createBlockStorage(tx); err != nil {
 		return err
 	}
 
-	d.MachineID = machine.ID
-	log.Info("Waiting for IP address to become available...")
-	for {
-		machine, err = client.GetServer(d.MachineID)
-		if err != nil {
+	if d.ManagedFirewall {
+		if err := d.createFirewallGroup(tx); err != nil {
 			return err
 		}
-		d.IPAddress = machine.MainIP
-		d.PrivateIP = machine.InternalIP
+	}
 
-		if d.IPAddress != "" && d.IPAddress != "0" && d.IPAddress != "0.0.0.0" {
-			break
+	if d.CreateReservedIP {
+		if err := d.createReservedIP(tx); err != nil {
+			return err
 		}
-		log.Debug("IP address not yet available")
-		time.Sleep(2 * time.Second)
 	}
 
-	if d.PrivateIP == "0" {
-		d.PrivateIP = ""
+	machine, err := d.createServer(tx, &vultr.ServerOptions{
+		SSHKey:               d.sshKeyIDs(),
+		IPV6:                 d.IPv6,
+		PrivateNetworking:    d.PrivateNetworking,
+		AutoBackups:          d.Backups,
+		Script:               scriptID,
+		IPXEChainURL:         d.IPXEChainURL,
+		UserData:             userdata,
+		Snapshot:             d.SnapshotID,
+		Hostname:             d.MachineName,
+		DontNotifyOnActivate: true,
+		Tag:                  d.VultrTag,
+		FirewallGroupID:      d.FirewallGroupID,
+		ReservedIP:           d.ReservedIP,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.MachineID = machine.ID
+	tx.record("VPS "+machine.ID, d.deleteServer)
+
+	log.Info("Waiting for the VPS to become ready...")
+	stop, release := notifyInterrupt()
+	defer release()
+	if err := d.waitForServer(stop); err != nil {
+		return err
+	}
+
+	if err := d.attachBlockStorage(tx); err != nil {
+		return err
 	}
 
-	log.Infof("Created Vultr VPS ID: %s, Public IP: %s, Private IP: %s",
+	if err := d.createDNSRecords(tx); err != nil {
+		return err
+	}
+
+	if err := d.setReverseDNS(); err != nil {
+		return err
+	}
+
+	if d.OSID == 159 && !d.customPXE() {
+		profile, err := d.profile()
+		if err != nil {
+			return err
+		}
+		if err := profile.PostCreate(d); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Created Vultr VPS ID: %s in region ID %d, Public IP: %s, IPv6: %s, Private IP: %s",
 		d.MachineID,
+		d.RegionID,
 		d.IPAddress,
+		d.IPv6Address,
 		d.PrivateIP,
 	)
 
@@ -385,24 +785,6 @@ func (d *Driver) getPublicKeyByID(id string) (*vultr.SSHKey, error) {
 	return nil, fmt.Errorf("Vultr SSH key with ID %s doesn't exist", id)
 }
 
-func (d *Driver) createSSHKey() (*vultr.SSHKey, error) {
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
-		return nil, err
-	}
-
-	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
-	if err != nil {
-		return nil, err
-	}
-
-	key, err := d.getClient().CreateSSHKey(d.MachineName, string(publicKey))
-	if err != nil {
-		return &key, err
-	}
-
-	return &key, nil
-}
-
 func (d *Driver) GetURL() (string, error) {
 	s, err := d.GetState()
 	if err != nil {
@@ -418,40 +800,38 @@ func (d *Driver) GetURL() (string, error) {
 		return "", err
 	}
 
-	return fmt.Sprintf("tcp://%s:2376", ip), nil
+	return dockerURL(ip), nil
 }
 
+// GetIP returns the IP address selected by --vultr-address-mode, or the
+// FQDN of the VPS when --vultr-dns-use-fqdn is set.
 func (d *Driver) GetIP() (string, error) {
-	if d.IPAddress == "" || d.IPAddress == "0" || d.IPAddress == "0.0.0.0" {
-		return "", fmt.Errorf("IP address is not set")
+	ip, err := d.address()
+	if err != nil {
+		return "", err
+	}
+
+	if d.DNSUseFQDN && d.DNSDomain != "" && d.AddressMode != addressPrivate {
+		return d.fqdn(), nil
 	}
 
-	return d.IPAddress, nil
+	return ip, nil
 }
 
 func (d *Driver) GetState() (state.State, error) {
+	if d.MachineID == "" {
+		return state.Error, mcnerror.ErrHostDoesNotExist{Name: d.MachineName}
+	}
+
 	machine, err := d.getClient().GetServer(d.MachineID)
+	if vultr.IsNotFound(err) {
+		return state.Error, mcnerror.ErrHostDoesNotExist{Name: d.MachineName}
+	}
 	if err != nil {
 		return state.Error, err
 	}
 
-	switch machine.Status {
-	case "pending":
-		return state.Starting, nil
-	case "active":
-		switch machine.ServerState {
-		case "ok":
-			switch machine.PowerStatus {
-			case "running":
-				return state.Running, nil
-			case "stopped":
-				return state.Stopped, nil
-			}
-		default:
-			return state.Starting, nil
-		}
-	}
-	return state.None, nil
+	return d.machineState(machine)
 }
 
 func (d *Driver) Start() error {
@@ -479,19 +859,43 @@ func (d *Driver) Stop() error {
 }
 
 func (d *Driver) Remove() error {
-	client := d.getClient()
 	log.Debugf("removing %s", d.MachineName)
-	if err := client.DeleteServer(d.MachineID); err != nil {
-		if strings.Contains(err.Error(), "Invalid server") {
+	if err := d.removeDNSRecords(); err != nil {
+		return err
+	}
+
+	if err := d.removeBlockStorage(); err != nil {
+		return err
+	}
+
+	if err := d.resetReverseDNS(); err != nil {
+		return err
+	}
+
+	if err := d.detachReservedIP(); err != nil {
+		return err
+	}
+
+	machineID := d.MachineID
+	if err := d.deleteServer(); err != nil {
+		if vultr.IsNotFound(err) {
 			log.Infof("VPS doesn't exist, assuming it is already deleted")
 		} else {
 			return err
 		}
 	}
 
-	if d.PxeScriptID != 0 && !d.CustomPxeScript {
-		if err := client.DeleteStartupScript(strconv.Itoa(d.PxeScriptID)); err != nil {
-			if strings.Contains(err.Error(), "Check SCRIPTID") {
+	if err := d.removeFirewallGroup(machineID); err != nil {
+		return err
+	}
+
+	if err := d.removeReservedIP(); err != nil {
+		return err
+	}
+
+	if !d.CustomPxeScript {
+		if err := d.releasePXEScript(); err != nil {
+			if vultr.IsNotFound(err) {
 				log.Infof("PXE script doesn't exist, assuming it is already deleted")
 			} else {
 				return err
@@ -499,9 +903,17 @@ func (d *Driver) Remove() error {
 		}
 	}
 
+	if err := d.releaseBootScript(); err != nil {
+		return err
+	}
+
+	if err := d.releaseAuthorizedKeys(); err != nil {
+		return err
+	}
+
 	if d.VultrPublicKey == "" {
-		if err := client.DeleteSSHKey(d.SSHKeyID); err != nil {
-			if strings.Contains(err.Error(), "Invalid SSH Key") {
+		if err := d.releaseSSHKey(); err != nil {
+			if vultr.IsNotFound(err) {
 				log.Infof("SSH key doesn't exist, assuming it is already deleted")
 			} else {
 				return err
@@ -512,6 +924,30 @@ func (d *Driver) Remove() error {
 	return nil
 }
 
+// deleteServer destroys the VPS. It is a no-op if the VPS was never created.
+func (d *Driver) deleteServer() error {
+	if d.MachineID == "" {
+		return nil
+	}
+	if err := d.getClient().DeleteServer(d.MachineID); err != nil {
+		return err
+	}
+	d.MachineID = ""
+	return nil
+}
+
+// deleteBootScript removes the PXE script created by createBootScript.
+func (d *Driver) deleteBootScript() error {
+	if d.PxeScriptID == 0 {
+		return nil
+	}
+	if err := d.getClient().DeleteStartupScript(strconv.Itoa(d.PxeScriptID)); err != nil {
+		return err
+	}
+	d.PxeScriptID = 0
+	return nil
+}
+
 func (d *Driver) Restart() error {
 	if vmState, err := d.GetState(); err != nil {
 		return err
@@ -548,14 +984,28 @@ func (d *Driver) getClient() *vultr.Client {
 	return d.client
 }
 
+// publicKey returns the public SSH key authorized on the VPS
+func (d *Driver) publicKey() (string, error) {
+	if d.VultrPublicKey != "" {
+		return d.VultrPublicKey, nil
+	}
+
+	keyByte, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return "", err
+	}
+	return string(keyByte), nil
+}
+
 func (d *Driver) publicSSHKeyPath() string {
 	return d.GetSSHKeyPath() + ".pub"
 }
 
 func (d *Driver) GetSSHKeyPath() string {
-	// don't set SSHKeyPath when using an existing SSH key
+	// don't set SSHKeyPath when using an existing SSH key, unless its
+	// private key was given by --vultr-ssh-private-key
 	if d.SSHKeyPath == "" && d.VultrPublicKey == "" {
-		d.SSHKeyPath = d.ResolveStorePath("id_rsa")
+		d.SSHKeyPath = d.ResolveStorePath(sshKeyFile(d.SSHKeyType))
 	}
 
 	return d.SSHKeyPath
@@ -577,6 +1027,9 @@ func (d *Driver) instanceIsRunning() bool {
 
 func (d *Driver) validateApiCredentials() error {
 	_, err := d.getClient().GetAccountInfo()
+	if vultr.IsAuthError(err) {
+		return fmt.Errorf("Vultr API key is invalid or lacks the required permissions: %v", err)
+	}
 	if err != nil {
 		return err
 	}
@@ -584,118 +1037,65 @@ func (d *Driver) validateApiCredentials() error {
 	return nil
 }
 
-func (d *Driver) validateRegion() error {
-	regions, err := d.getClient().GetRegions()
-	if err != nil {
-		return err
-	}
+// resolveNames translates the region, plan and OS names given on the
+// command line into the numeric IDs expected by the Vultr API
+func (d *Driver) resolveNames() error {
+	client := d.getClient()
 
-	for _, region := range regions {
-		if region.ID == d.RegionID {
-			return nil
+	if d.regionName != "" {
+		regions, err := client.GetRegions()
+		if err != nil {
+			return err
+		}
+		if d.regionIDs, err = resolveRegions(regions, d.regionName); err != nil {
+			return err
 		}
+		d.RegionID = d.regionIDs[0]
+		log.Debugf("Resolved region '%s' to ID %v", d.regionName, d.regionIDs)
 	}
 
-	return fmt.Errorf("Region ID %d is invalid", d.RegionID)
-}
-
-func (d *Driver) validatePlan() error {
-	plans, err := d.getClient().GetAvailablePlansForRegion(d.RegionID)
-	if err != nil {
-		return err
+	if d.planName != "" {
+		plans, err := client.GetPlans()
+		if err != nil {
+			return err
+		}
+		if d.PlanID, err = resolvePlan(plans, d.planName); err != nil {
+			return err
+		}
+		log.Debugf("Resolved plan '%s' to ID %d", d.planName, d.PlanID)
 	}
 
-	for _, v := range plans {
-		if v == d.PlanID {
-			return nil
+	if d.osName != "" {
+		oses, err := client.GetOS()
+		if err != nil {
+			return err
 		}
+		if d.OSID, err = resolveOS(oses, d.osName); err != nil {
+			return err
+		}
+		log.Debugf("Resolved OS '%s' to ID %d", d.osName, d.OSID)
 	}
 
-	return fmt.Errorf("PlanID %d not available in the chosen region. Available plans for RegionID %d: %v", d.PlanID, d.RegionID, plans)
+	return nil
 }
 
-// RancherOS - Create iPXE script
-func (d *Driver) createBootScript() error {
-	content := `#!ipxe
-set base-url http://releases.rancher.com/os/%s
-kernel ${base-url}/vmlinuz rancher.state.dev=LABEL=RANCHER_STATE rancher.state.autoformat=[/dev/vda] rancher.state.formatzero rancher.cloud_init.datasources=[ec2]
-initrd ${base-url}/initrd
-boot`
-
-	content = fmt.Sprintf(content, d.ROSVersion)
-	log.Debugf("Using the following PXE script:")
-	log.Debugf("%s", content)
-	script, err := d.getClient().CreateStartupScript(d.MachineName, content, "pxe")
-	if err != nil {
-		return err
-	}
-	d.PxeScriptID, err = strconv.Atoi(script.ID)
+// getRegion returns the region the VPS is created in
+func (d *Driver) getRegion() (*vultr.Region, error) {
+	regions, err := d.getClient().GetRegions()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
-}
-
-// RancherOS - Generate cloud-config userdata string that will
-// provision the SSH Key to the VPS and configure private networking
-func (d *Driver) getCloudConfig() (string, error) {
-	type userData struct {
-		HostName     string
-		SSHkey       string
-		PrivateNet   bool
-		CustomScript bool
-	}
-
-	const tpl = `#cloud-config
-hostname: {{.HostName}}
-ssh_authorized_keys:
-  - {{.SSHkey}}{{if not .CustomScript}}
-write_files:
-  - path: /opt/rancher/bin/start.sh
-    permissions: "0755"
-    owner: root
-    content: |
-      #!/bin/sh
-      mount | grep /dev/vda >/dev/null
-      RETVAL=$?
-      if [ $RETVAL -eq 0 ]; then
-        exit 0
-      fi
-      sudo dd if=/dev/zero of=/dev/vda bs=1M count=1
-      logger -t start.sh "Prepared /dev/vda for use as Rancher state disk. Rebooting."
-      sudo reboot
-rancher:
-  network:
-    interfaces:
-      eth0:
-        dhcp: true{{if .PrivateNet}}
-      eth1:
-        address: $private_ipv4/16
-        mtu: 1450{{end}}{{end}}
-`
-	var buffer bytes.Buffer
-	var publicKey string
 
-	if d.VultrPublicKey != "" {
-		publicKey = d.VultrPublicKey
-	} else {
-		keyByte, err := ioutil.ReadFile(d.publicSSHKeyPath())
-		if err != nil {
-			return "", err
+	for _, region := range regions {
+		if region.ID == d.RegionID {
+			return &region, nil
 		}
-		publicKey = string(keyByte)
 	}
 
-	config := userData{HostName: d.MachineName, SSHkey: publicKey, PrivateNet: d.PrivateNetworking, CustomScript: d.CustomPxeScript}
-	tmpl, err := template.New("cloud-config").Parse(tpl)
-	if err != nil {
-		return "", err
-	}
-
-	err = tmpl.Execute(&buffer, config)
-	if err != nil {
-		return "", err
-	}
+	return nil, fmt.Errorf("Region ID %d is invalid", d.RegionID)
+}
 
-	return buffer.String(), nil
+func (d *Driver) validateRegion() error {
+	_, err := d.getRegion()
+	return err
 }
@@ -1,8 +1,13 @@
 package vultr
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	vultr "github.com/JamesClonk/vultr/lib"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/stretchr/testify/assert"
 )
@@ -24,3 +29,33 @@ func TestSetConfigFromFlags(t *testing.T) {
 
 	assert.Equal(t, driver.ResolveStorePath("id_rsa"), driver.GetSSHKeyPath())
 }
+
+// newTestDriver returns a driver whose API client talks to a test server
+// serving the given responses, keyed by API path (e.g. "/v1/server/list").
+// Requested paths are appended to calls.
+func newTestDriver(responses map[string]string, calls *[]string) (*Driver, *httptest.Server) {
+	return newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		if calls != nil {
+			*calls = append(*calls, r.URL.Path)
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, body)
+	})
+}
+
+// newHandlerTestDriver returns a driver whose API calls are answered by
+// handler, for tests that need more than fixed responses.
+func newHandlerTestDriver(handler http.HandlerFunc) (*Driver, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	driver := NewDriver("default", "path")
+	driver.client = vultr.NewClient("APIKEY", &vultr.Options{
+		Endpoint:       server.URL,
+		RateLimitation: time.Millisecond,
+	})
+	return driver, server
+}
@@ -0,0 +1,168 @@
+package vultr
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var (
+	waitInitialInterval = 2 * time.Second
+	waitMaxInterval     = 30 * time.Second
+
+	// errWaitTimeout is returned by waitFor when the condition is not met
+	// before the deadline.
+	errWaitTimeout = fmt.Errorf("timeout")
+	// errWaitCancelled is returned by waitFor when the stop channel is closed.
+	errWaitCancelled = fmt.Errorf("cancelled")
+)
+
+// waitFor calls poll until it reports done, returns an error or the timeout
+// expires. The interval between two calls starts at initial and doubles
+// after every attempt up to max. The stop channel aborts the wait early.
+func waitFor(timeout, initial, max time.Duration, stop <-chan struct{}, poll func() (bool, error)) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	interval := initial
+	for {
+		done, err := poll()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-deadline.C:
+			return errWaitTimeout
+		case <-stop:
+			return errWaitCancelled
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > max {
+			interval = max
+		}
+	}
+}
+
+// serverReady reports whether the VPS has been assigned a public IP and
+// finished booting.
+func serverReady(machine vultr.Server) bool {
+	return isValidIP(machine.MainIP) &&
+		machine.Status == "active" &&
+		machine.ServerState == "ok" &&
+		machine.PowerStatus == "running"
+}
+
+// addressesAssigned reports whether the VPS has been assigned all the
+// addresses required by the enabled networking features.
+func (d *Driver) addressesAssigned(machine vultr.Server) bool {
+	if d.IPv6 && len(machine.V6Networks) == 0 {
+		return false
+	}
+	if d.PrivateNetworking && !isValidIP(machine.InternalIP) {
+		return false
+	}
+	return true
+}
+
+// isTransientError reports whether a failed API call is worth retrying:
+// rate limiting, server side errors and network failures.
+func isTransientError(err error) bool {
+	if apiErr, ok := err.(*vultr.APIError); ok {
+		return vultr.IsRateLimited(err) || apiErr.StatusCode >= 500
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
+
+func isValidIP(ip string) bool {
+	return ip != "" && ip != "0" && ip != "0.0.0.0"
+}
+
+// waitForServer polls the VPS until it is ready or CreateTimeout expires.
+// Transient API errors are retried, only permanent ones end the wait.
+// On success the IP addresses of the VPS are stored in the driver.
+func (d *Driver) waitForServer(stop <-chan struct{}) error {
+	var machine vultr.Server
+	timeout := time.Duration(d.CreateTimeout) * time.Second
+
+	err := waitFor(timeout, waitInitialInterval, waitMaxInterval, stop, func() (bool, error) {
+		server, err := d.getClient().GetServer(d.MachineID)
+		if isTransientError(err) {
+			log.Debugf("Error polling VPS %s, retrying: %v", d.MachineID, err)
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		machine = server
+		if serverReady(machine) && d.addressesAssigned(machine) {
+			return true, nil
+		}
+		log.Debugf("VPS not yet ready (%s)", describeServer(machine))
+		return false, nil
+	})
+	switch err {
+	case nil:
+	case errWaitTimeout:
+		return fmt.Errorf("Timed out after %s waiting for VPS %s to become ready. Last seen: %s",
+			timeout, d.MachineID, describeServer(machine))
+	case errWaitCancelled:
+		return fmt.Errorf("Interrupted while waiting for VPS %s to become ready. Last seen: %s",
+			d.MachineID, describeServer(machine))
+	default:
+		return err
+	}
+
+	d.IPAddress = machine.MainIP
+	d.PrivateIP = machine.InternalIP
+	if d.PrivateIP == "0" {
+		d.PrivateIP = ""
+	}
+	if len(machine.V6Networks) > 0 {
+		d.IPv6Address = machine.V6Networks[0].MainIP
+	}
+
+	return nil
+}
+
+func describeServer(machine vultr.Server) string {
+	return fmt.Sprintf("status: %q, server state: %q, power status: %q, IP: %q",
+		machine.Status, machine.ServerState, machine.PowerStatus, machine.MainIP)
+}
+
+// notifyInterrupt returns a channel that is closed when the plugin receives
+// SIGINT or SIGTERM, so that a pending wait can be aborted and the created
+// resources rolled back. The release function must be called once the
+// channel is no longer needed.
+func notifyInterrupt() (<-chan struct{}, func()) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-interrupt:
+			log.Info("Interrupted, aborting...")
+			close(stop)
+		case <-done:
+		}
+	}()
+
+	return stop, func() {
+		signal.Stop(interrupt)
+		close(done)
+	}
+}
@@ -0,0 +1,99 @@
+package vultr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	vultr "github.com/JamesClonk/vultr/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitFor(t *testing.T) {
+	calls := 0
+	err := waitFor(time.Second, time.Millisecond, 4*time.Millisecond, nil, func() (bool, error) {
+		calls++
+		return calls == 5, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, calls)
+
+	err = waitFor(20*time.Millisecond, time.Millisecond, 2*time.Millisecond, nil, func() (bool, error) {
+		return false, nil
+	})
+	assert.Equal(t, errWaitTimeout, err)
+
+	stop := make(chan struct{})
+	close(stop)
+	err = waitFor(time.Second, 100*time.Millisecond, time.Second, stop, func() (bool, error) {
+		return false, nil
+	})
+	assert.Equal(t, errWaitCancelled, err)
+
+	pollErr := errors.New("API error")
+	err = waitFor(time.Second, time.Millisecond, time.Millisecond, nil, func() (bool, error) {
+		return false, pollErr
+	})
+	assert.Equal(t, pollErr, err)
+}
+
+func TestServerReady(t *testing.T) {
+	tests := []struct {
+		server vultr.Server
+		ready  bool
+	}{
+		{vultr.Server{MainIP: "0.0.0.0", Status: "pending", ServerState: "none", PowerStatus: "stopped"}, false},
+		{vultr.Server{MainIP: "1.2.3.4", Status: "pending", ServerState: "none", PowerStatus: "stopped"}, false},
+		{vultr.Server{MainIP: "1.2.3.4", Status: "active", ServerState: "installingbooting", PowerStatus: "running"}, false},
+		{vultr.Server{MainIP: "1.2.3.4", Status: "active", ServerState: "ok", PowerStatus: "stopped"}, false},
+		{vultr.Server{MainIP: "0", Status: "active", ServerState: "ok", PowerStatus: "running"}, false},
+		{vultr.Server{MainIP: "1.2.3.4", Status: "active", ServerState: "ok", PowerStatus: "running"}, true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.ready, serverReady(tt.server), describeServer(tt.server))
+	}
+}
+
+func TestWaitForServerRetriesTransientErrors(t *testing.T) {
+	defer func(initial, max time.Duration) {
+		waitInitialInterval, waitMaxInterval = initial, max
+	}(waitInitialInterval, waitMaxInterval)
+	waitInitialInterval, waitMaxInterval = time.Millisecond, time.Millisecond
+
+	polls := 0
+	driver, server := newHandlerTestDriver(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "Rate limit reached")
+			return
+		}
+		fmt.Fprint(w, `{"SUBID":"576965","main_ip":"1.2.3.4","status":"active","server_state":"ok","power_status":"running"}`)
+	})
+	defer server.Close()
+
+	driver.MachineID = "576965"
+	driver.CreateTimeout = 5
+
+	assert.NoError(t, driver.waitForServer(nil))
+	assert.Equal(t, 2, polls)
+	assert.Equal(t, "1.2.3.4", driver.IPAddress)
+}
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, isTransientError(&vultr.APIError{StatusCode: 503, Message: "Rate limit reached"}))
+	assert.True(t, isTransientError(&vultr.APIError{StatusCode: 500, Message: "Internal error"}))
+	assert.True(t, isTransientError(&timeoutError{}))
+	assert.False(t, isTransientError(&vultr.APIError{StatusCode: 412, Message: "Invalid server."}))
+	assert.False(t, isTransientError(errors.New("Invalid server")))
+	assert.False(t, isTransientError(nil))
+}
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string   { return "i/o timeout" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }